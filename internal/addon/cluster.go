@@ -0,0 +1,28 @@
+package addon
+
+import (
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+const (
+	// ClusterIDClaim is the ClusterClaim holding the ID of the managed cluster
+	ClusterIDClaim = "id.k8s.io"
+	// ClusterIDLabel is the label set by OCM with the ID of the managed cluster
+	ClusterIDLabel = "clusterID"
+)
+
+// ClusterID returns the ID of the managed cluster, so that every signal
+// identifies the cluster the same way. The ID reported by the cluster through
+// a ClusterClaim takes precedence over the label set by OCM.
+func ClusterID(cluster *clusterv1.ManagedCluster) (string, bool) {
+	if cluster == nil {
+		return "", false
+	}
+	for _, claim := range cluster.Status.ClusterClaims {
+		if claim.Name == ClusterIDClaim && claim.Value != "" {
+			return claim.Value, true
+		}
+	}
+	id, ok := cluster.Labels[ClusterIDLabel]
+	return id, ok
+}
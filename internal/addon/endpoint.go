@@ -0,0 +1,184 @@
+package addon
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	endpointKindRoute   = "route"
+	endpointKindIngress = "ingress"
+	endpointKindService = "service"
+)
+
+// DiscoverURL returns the HTTPS URL of the Route, Ingress or LoadBalancer
+// Service referenced, as kind/namespace/name, by the customized variable. The
+// port is part of the host unless it is the HTTPS one. The in-cluster URL of
+// the Service behind them is returned for the hub cluster.
+func DiscoverURL(k8sClient client.Client, valueKey, ref string, inCluster bool) (*url.URL, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return nil, kverrors.New("invalid endpoint reference, expected kind/namespace/name", "name", valueKey, "value", ref)
+	}
+	kind, key := strings.ToLower(parts[0]), client.ObjectKey{Namespace: parts[1], Name: parts[2]}
+
+	var (
+		u   = &url.URL{Scheme: "https"}
+		err error
+	)
+	switch kind {
+	case endpointKindRoute:
+		u, err = discoverRouteURL(k8sClient, key, inCluster)
+	case endpointKindIngress:
+		u.Host, err = discoverIngressHost(k8sClient, key, inCluster)
+	case endpointKindService:
+		u.Host, err = discoverServiceHost(k8sClient, key, inCluster)
+	default:
+		return nil, kverrors.New("unsupported endpoint kind, expected route, ingress or service", "name", valueKey, "kind", parts[0])
+	}
+	if err != nil {
+		return nil, kverrors.Wrap(err, "failed to discover the endpoint", "name", valueKey, "kind", kind, "resource", key.Name, "namespace", key.Namespace)
+	}
+	return u, nil
+}
+
+// discoverRouteURL returns the URL of the host of the Route. In-cluster, the
+// URL of the Service behind it is returned, with the scheme it serves.
+func discoverRouteURL(k8sClient client.Client, key client.ObjectKey, inCluster bool) (*url.URL, error) {
+	route := &routev1.Route{}
+	if err := k8sClient.Get(context.Background(), key, route, &client.GetOptions{}); err != nil {
+		return nil, err
+	}
+	if inCluster {
+		host, err := RouteServiceHost(k8sClient, route)
+		if err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: RouteServiceScheme(route), Host: host}, nil
+	}
+	if route.Spec.Host == "" {
+		return nil, kverrors.New("route without host")
+	}
+	return &url.URL{Scheme: "https", Host: route.Spec.Host}, nil
+}
+
+// discoverIngressHost returns the host of the first rule of the Ingress,
+// falling back to the address of its load balancer. In-cluster, the host of
+// the Service backing the first path is returned.
+func discoverIngressHost(k8sClient client.Client, key client.ObjectKey, inCluster bool) (string, error) {
+	ingress := &networkingv1.Ingress{}
+	if err := k8sClient.Get(context.Background(), key, ingress, &client.GetOptions{}); err != nil {
+		return "", err
+	}
+	if inCluster {
+		return ingressServiceHost(k8sClient, ingress)
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			return rule.Host, nil
+		}
+	}
+	for _, lb := range ingress.Status.LoadBalancer.Ingress {
+		if address := firstNonEmpty(lb.Hostname, lb.IP); address != "" {
+			return address, nil
+		}
+	}
+	return "", kverrors.New("ingress without host nor load balancer address")
+}
+
+// discoverServiceHost returns the address of the load balancer of the
+// Service with its first port, omitted when it is the HTTPS one.
+func discoverServiceHost(k8sClient client.Client, key client.ObjectKey, inCluster bool) (string, error) {
+	svc := &corev1.Service{}
+	if err := k8sClient.Get(context.Background(), key, svc, &client.GetOptions{}); err != nil {
+		return "", err
+	}
+	if inCluster && len(svc.Spec.Ports) > 0 {
+		return ServiceHost(svc.Name, svc.Namespace, svc.Spec.Ports[0].Port), nil
+	}
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return "", kverrors.New("service must be of type LoadBalancer to be reachable from the managed clusters", "type", svc.Spec.Type)
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return "", kverrors.New("service without ports")
+	}
+
+	address := ""
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		if address = firstNonEmpty(lb.Hostname, lb.IP); address != "" {
+			break
+		}
+	}
+	if address == "" {
+		return "", kverrors.New("service without load balancer address")
+	}
+
+	if port := svc.Spec.Ports[0].Port; port != 443 {
+		return net.JoinHostPort(address, strconv.Itoa(int(port))), nil
+	}
+	if strings.Contains(address, ":") {
+		// IPv6 addresses must be bracketed in URLs
+		return "[" + address + "]", nil
+	}
+	return address, nil
+}
+
+// ingressServiceHost returns the in-cluster host of the Service backing the
+// first path of the Ingress.
+func ingressServiceHost(k8sClient client.Client, ingress *networkingv1.Ingress) (string, error) {
+	var backend *networkingv1.IngressServiceBackend
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service != nil {
+				backend = p.Backend.Service
+				break
+			}
+		}
+		if backend != nil {
+			break
+		}
+	}
+	if backend == nil && ingress.Spec.DefaultBackend != nil {
+		backend = ingress.Spec.DefaultBackend.Service
+	}
+	if backend == nil {
+		return "", kverrors.New("ingress without service backend")
+	}
+
+	if backend.Port.Number != 0 {
+		return ServiceHost(backend.Name, ingress.Namespace, backend.Port.Number), nil
+	}
+
+	svc := &corev1.Service{}
+	key := client.ObjectKey{Name: backend.Name, Namespace: ingress.Namespace}
+	if err := k8sClient.Get(context.Background(), key, svc, &client.GetOptions{}); err != nil {
+		return "", kverrors.Wrap(err, "failed to get the service of the ingress", "name", key.Name)
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Name == backend.Port.Name {
+			return ServiceHost(svc.Name, svc.Namespace, p.Port), nil
+		}
+	}
+	return "", kverrors.New("ingress backend port not found in the service", "name", key.Name, "port", backend.Port.Name)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package addon
+
+import (
+	"testing"
+
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = routev1.AddToScheme(scheme.Scheme)
+
+func Test_DiscoverURL(t *testing.T) {
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gateway",
+			Namespace: "observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "gateway.apps.example.com",
+			To:   routev1.RouteTargetReference{Kind: "Service", Name: "gateway"},
+			Port: &routev1.RoutePort{TargetPort: intstr.FromString("public")},
+			TLS:  &routev1.TLSConfig{Termination: routev1.TLSTerminationReencrypt},
+		},
+	}
+	edgeRoute := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gateway-edge",
+			Namespace: "observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "gateway-edge.apps.example.com",
+			To:   routev1.RouteTargetReference{Kind: "Service", Name: "gateway"},
+			Port: &routev1.RoutePort{TargetPort: intstr.FromString("internal")},
+			TLS:  &routev1.TLSConfig{Termination: routev1.TLSTerminationEdge},
+		},
+	}
+	routeService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gateway",
+			Namespace: "observability",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "internal", Port: 8081},
+				{Name: "public", Port: 8080},
+			},
+		},
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "receiver",
+			Namespace: "thanos",
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: "receive.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: "receiver",
+											Port: networkingv1.ServiceBackendPort{Name: "remote-write"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	ingressService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "receiver",
+			Namespace: "thanos",
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{{Name: "remote-write", Port: 19291}},
+		},
+	}
+	lbService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "receiver-lb",
+			Namespace: "thanos",
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Port: 443}},
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{Hostname: "receiver.elb.example.com"}},
+			},
+		},
+	}
+	lbServiceIPv6 := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "receiver-lb-ipv6",
+			Namespace: "thanos",
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Port: 19291}},
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "2001:db8::1"}},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		ref       string
+		inCluster bool
+		url       string
+		wantErr   bool
+	}{
+		{
+			name: "Route",
+			ref:  "route/observability/gateway",
+			url:  "https://gateway.apps.example.com",
+		},
+		{
+			name:      "RouteInCluster",
+			ref:       "Route/observability/gateway",
+			inCluster: true,
+			url:       "https://gateway.observability.svc:8080",
+		},
+		{
+			name: "EdgeRoute",
+			ref:  "route/observability/gateway-edge",
+			url:  "https://gateway-edge.apps.example.com",
+		},
+		{
+			name:      "EdgeRouteInCluster",
+			ref:       "route/observability/gateway-edge",
+			inCluster: true,
+			url:       "http://gateway.observability.svc:8081",
+		},
+		{
+			name: "Ingress",
+			ref:  "ingress/thanos/receiver",
+			url:  "https://receive.example.com",
+		},
+		{
+			name:      "IngressInCluster",
+			ref:       "ingress/thanos/receiver",
+			inCluster: true,
+			url:       "https://receiver.thanos.svc:19291",
+		},
+		{
+			name: "LoadBalancerService",
+			ref:  "service/thanos/receiver-lb",
+			url:  "https://receiver.elb.example.com",
+		},
+		{
+			name: "LoadBalancerServiceIPv6",
+			ref:  "service/thanos/receiver-lb-ipv6",
+			url:  "https://[2001:db8::1]:19291",
+		},
+		{
+			name:    "ClusterIPService",
+			ref:     "service/thanos/receiver",
+			wantErr: true,
+		},
+		{
+			name:      "ClusterIPServiceInCluster",
+			ref:       "service/thanos/receiver",
+			inCluster: true,
+			url:       "https://receiver.thanos.svc:19291",
+		},
+		{
+			name:    "NotFound",
+			ref:     "route/thanos/receiver",
+			wantErr: true,
+		},
+		{
+			name:    "MissingKind",
+			ref:     "thanos/receiver",
+			wantErr: true,
+		},
+		{
+			name:    "EmptyName",
+			ref:     "route/thanos/",
+			wantErr: true,
+		},
+		{
+			name:    "UnsupportedKind",
+			ref:     "gateway/thanos/receiver",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			k8s := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithObjects(route, edgeRoute, routeService, ingress, ingressService, lbService, lbServiceIPv6).
+				Build()
+
+			u, err := DiscoverURL(k8s, "endpointRef", tc.ref, tc.inCluster)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.url, u.String())
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/rhobs/multicluster-observability-addon/internal/metrics"
 	thandlers "github.com/rhobs/multicluster-observability-addon/internal/tracing/handlers"
 	tmanifests "github.com/rhobs/multicluster-observability-addon/internal/tracing/manifests"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
 	addonutils "open-cluster-management.io/addon-framework/pkg/utils"
@@ -20,11 +21,27 @@ import (
 )
 
 type HelmChartValues struct {
+	Global  GlobalValues             `json:"global"`
 	Metrics metrics.MetricsValues    `json:"metrics"`
 	Logging lmanifests.LoggingValues `json:"logging"`
 	Tracing tmanifests.TracingValues `json:"tracing"`
 }
 
+// GlobalValues are the values shared by all the subcharts.
+type GlobalValues struct {
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+	ProxyConfig  *ProxyValues        `json:"proxyConfig,omitempty"`
+}
+
+// ProxyValues are the proxy settings used by the agents to reach the hub or
+// any other destination of the signals.
+type ProxyValues struct {
+	HTTPProxy  string `json:"httpProxy"`
+	HTTPSProxy string `json:"httpsProxy"`
+	NoProxy    string `json:"noProxy"`
+}
+
 type Options struct {
 	MetricsDisabled bool
 	LoggingDisabled bool
@@ -52,6 +69,19 @@ func GetValuesFunc(k8s client.Client) addonfactory.GetValuesFunc {
 
 		var userValues HelmChartValues
 
+		if aodc.Spec.NodePlacement != nil {
+			userValues.Global.NodeSelector = aodc.Spec.NodePlacement.NodeSelector
+			userValues.Global.Tolerations = aodc.Spec.NodePlacement.Tolerations
+		}
+
+		if proxy := aodc.Spec.ProxyConfig; proxy.HTTPProxy != "" || proxy.HTTPSProxy != "" {
+			userValues.Global.ProxyConfig = &ProxyValues{
+				HTTPProxy:  proxy.HTTPProxy,
+				HTTPSProxy: proxy.HTTPSProxy,
+				NoProxy:    proxy.NoProxy,
+			}
+		}
+
 		if !opts.MetricsDisabled {
 			metrics, err := metrics.GetValuesFunc(k8s, cluster, addon, aodc)
 			if err != nil {
@@ -61,7 +91,7 @@ func GetValuesFunc(k8s client.Client) addonfactory.GetValuesFunc {
 		}
 
 		if !opts.LoggingDisabled {
-			loggingOpts, err := lhandlers.BuildOptions(k8s, addon, aodc)
+			loggingOpts, err := lhandlers.BuildOptions(k8s, cluster, addon, aodc)
 			if err != nil {
 				return nil, err
 			}
@@ -75,7 +105,7 @@ func GetValuesFunc(k8s client.Client) addonfactory.GetValuesFunc {
 
 		if !opts.TracingDisabled {
 			klog.Info("Tracing enabled")
-			tracingOpts, err := thandlers.BuildOptions(k8s, addon, aodc)
+			tracingOpts, err := thandlers.BuildOptions(k8s, cluster, addon, aodc)
 			if err != nil {
 				return nil, err
 			}
@@ -10,6 +10,8 @@ import (
 	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/rhobs/multicluster-observability-addon/internal/addon"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -111,3 +113,133 @@ func Test_Mcoa_Disable_Charts(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 2, len(objects))
 }
+
+func Test_Mcoa_AddOnDeploymentConfig_MetricsAgent(t *testing.T) {
+	var (
+		managedCluster        *clusterv1.ManagedCluster
+		managedClusterAddOn   *addonapiv1alpha1.ManagedClusterAddOn
+		addOnDeploymentConfig *addonapiv1alpha1.AddOnDeploymentConfig
+	)
+
+	managedCluster = addontesting.NewManagedCluster("cluster-1")
+	managedClusterAddOn = addontesting.NewAddon("test", "cluster-1")
+
+	managedClusterAddOn.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    "addon.open-cluster-management.io",
+				Resource: "addondeploymentconfigs",
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Namespace: "open-cluster-management",
+				Name:      "multicluster-observability-addon",
+			},
+		},
+	}
+
+	addOnDeploymentConfig = &addonapiv1alpha1.AddOnDeploymentConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "multicluster-observability-addon",
+			Namespace: "open-cluster-management",
+		},
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+				{
+					Name:  "metricsDestinationEndpoint",
+					Value: "https://observatorium.example.com/api/metrics/v1/default/api/v1/receive",
+				},
+				{
+					Name:  "loggingDisabled",
+					Value: "true",
+				},
+				{
+					Name:  "tracingDisabled",
+					Value: "true",
+				},
+			},
+			NodePlacement: &addonapiv1alpha1.NodePlacement{
+				NodeSelector: map[string]string{
+					"node-role.kubernetes.io/infra": "",
+				},
+				Tolerations: []corev1.Toleration{
+					{
+						Key:      "node-role.kubernetes.io/infra",
+						Operator: corev1.TolerationOpExists,
+						Effect:   corev1.TaintEffectNoSchedule,
+					},
+				},
+			},
+			Registries: []addonapiv1alpha1.ImageMirror{
+				{
+					Source: "quay.io/prometheus",
+					Mirror: "registry.example.com/prometheus",
+				},
+			},
+			ProxyConfig: addonapiv1alpha1.ProxyConfig{
+				HTTPSProxy: "https://proxy.example.com:3129",
+				NoProxy:    "cluster.local",
+			},
+		},
+	}
+
+	certManagerCertificateCRD := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "certificates.cert-manager.io",
+		},
+	}
+	certManagerIssuerCRD := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "issuers.cert-manager.io",
+		},
+	}
+	certManagerClusterIssuerCRD := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "clusterissuers.cert-manager.io",
+		},
+	}
+
+	fakeKubeClient := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(addOnDeploymentConfig, certManagerCertificateCRD, certManagerIssuerCRD, certManagerClusterIssuerCRD).
+		Build()
+
+	mcoaAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, addon.McoaChartDir).
+		WithGetValuesFuncs(GetValuesFunc(fakeKubeClient)).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(scheme.Scheme).
+		BuildHelmAgentAddon()
+	if err != nil {
+		klog.Fatalf("failed to build agent %v", err)
+	}
+
+	objects, err := mcoaAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+
+	var (
+		deployment *appsv1.Deployment
+		configMap  *corev1.ConfigMap
+	)
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *appsv1.Deployment:
+			deployment = obj
+		case *corev1.ConfigMap:
+			if obj.Name == "prometheus-agent-conf" {
+				configMap = obj
+			}
+		}
+	}
+	require.NotNil(t, deployment)
+	require.NotNil(t, configMap)
+	require.Equal(t, addOnDeploymentConfig.Spec.NodePlacement.NodeSelector, deployment.Spec.Template.Spec.NodeSelector)
+	require.Equal(t, addOnDeploymentConfig.Spec.NodePlacement.Tolerations, deployment.Spec.Template.Spec.Tolerations)
+	require.Equal(t, "registry.example.com/prometheus/prometheus:v2.48.1", deployment.Spec.Template.Spec.Containers[0].Image)
+	require.Equal(t, []corev1.EnvVar{
+		{Name: "HTTPS_PROXY", Value: "https://proxy.example.com:3129"},
+		{Name: "NO_PROXY", Value: "cluster.local"},
+	}, deployment.Spec.Template.Spec.Containers[0].Env)
+	require.Contains(t, configMap.Data["prometheus.yml"], "proxy_from_environment: true")
+	require.Contains(t, configMap.Data["prometheus.yml"], "scrape_interval: 4m")
+	require.Contains(t, configMap.Data["prometheus.yml"], "external_labels:\n    cluster: \"cluster-1\"")
+	require.Contains(t, configMap.Data["prometheus.yml"], `- "{__name__=\"up\"}"`)
+}
@@ -0,0 +1,132 @@
+package addon
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	routev1 "github.com/openshift/api/route/v1"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LocalClusterLabel is set by OCM, with the value true, on the
+	// ManagedCluster of the hub when the hub manages itself
+	LocalClusterLabel = "local-cluster"
+	LocalClusterName  = "local-cluster"
+
+	// PartOfLabelKey is set to the name of the addon on the resources of the
+	// addon that need to be told apart on the hub
+	PartOfLabelKey = "app.kubernetes.io/part-of"
+)
+
+// IsHubCluster returns whether the managed cluster is the hub itself, in which
+// case the signals are sent to the hub services without leaving the cluster.
+// The hops skipped are the router and the proxy: the in-cluster services are
+// still the gateways that authenticate the tenant of every write, e.g. the
+// observatorium API and the LokiStack gateway, so the hub keeps the
+// credentials configured for its destinations and outputs.
+func IsHubCluster(cluster *clusterv1.ManagedCluster) bool {
+	if cluster == nil {
+		return false
+	}
+	if value, ok := cluster.Labels[LocalClusterLabel]; ok {
+		isHub, _ := strconv.ParseBool(value)
+		return isHub
+	}
+	return cluster.Name == LocalClusterName
+}
+
+// HubRunsOperator returns whether the operator package is subscribed on the
+// hub by something else than the addon, e.g. for the observability stack of
+// the hub. The addon must not install it a second time on the hub cluster.
+// The subscriptions of the addon carry its part-of label, or were applied by
+// the work agent from the addon ManifestWork before being labeled.
+func HubRunsOperator(k8s client.Client, packageName string) (bool, error) {
+	subscriptions := &operatorsv1alpha1.SubscriptionList{}
+	if err := k8s.List(context.Background(), subscriptions); err != nil {
+		return false, kverrors.Wrap(err, "failed to list the subscriptions of the hub", "package", packageName)
+	}
+
+	for _, subscription := range subscriptions.Items {
+		if subscription.Spec == nil || subscription.Spec.Package != packageName {
+			continue
+		}
+		if subscription.Labels[PartOfLabelKey] == Name || appliedByAddon(&subscription) {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// appliedByAddon returns whether the work agent applied the object from the
+// ManifestWork deploying the addon, which it owns through its
+// AppliedManifestWork.
+func appliedByAddon(obj metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.APIVersion != workv1.GroupVersion.String() || ref.Kind != "AppliedManifestWork" {
+			continue
+		}
+		// AppliedManifestWorks are named <hub hash>-<ManifestWork name>
+		if strings.Contains(ref.Name, constants.DeployWorkNamePrefix(Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteServiceHost returns the in-cluster host and port of the Service the
+// Route sends traffic to, which lets the hub cluster bypass its router.
+func RouteServiceHost(k8s client.Client, route *routev1.Route) (string, error) {
+	if route.Spec.To.Kind != "" && route.Spec.To.Kind != "Service" {
+		return "", kverrors.New("route doesn't target a service", "name", route.Name, "namespace", route.Namespace, "kind", route.Spec.To.Kind)
+	}
+
+	svc := &corev1.Service{}
+	key := client.ObjectKey{Name: route.Spec.To.Name, Namespace: route.Namespace}
+	if err := k8s.Get(context.Background(), key, svc, &client.GetOptions{}); err != nil {
+		return "", kverrors.Wrap(err, "failed to get the service of the route", "name", key.Name, "namespace", key.Namespace)
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return "", kverrors.New("service without ports", "name", key.Name, "namespace", key.Namespace)
+	}
+
+	// The target port of a Route is either the name or the target port of a
+	// port of the Service
+	port := svc.Spec.Ports[0]
+	if route.Spec.Port != nil {
+		target := route.Spec.Port.TargetPort
+		for _, p := range svc.Spec.Ports {
+			if p.Name == target.String() || p.TargetPort == target {
+				port = p
+				break
+			}
+		}
+	}
+
+	return ServiceHost(svc.Name, svc.Namespace, port.Port), nil
+}
+
+// RouteServiceScheme returns the scheme served by the Service the Route sends
+// traffic to. The router terminates TLS for edge and insecure Routes, which
+// forward plain HTTP to the Service.
+func RouteServiceScheme(route *routev1.Route) string {
+	if route.Spec.TLS == nil || route.Spec.TLS.Termination == routev1.TLSTerminationEdge {
+		return "http"
+	}
+	return "https"
+}
+
+// ServiceHost returns the in-cluster host and port of a Service.
+func ServiceHost(name, namespace string, port int32) string {
+	return fmt.Sprintf("%s.%s.svc:%d", name, namespace, port)
+}
@@ -0,0 +1,83 @@
+package addon
+
+import (
+	"testing"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = operatorsv1alpha1.AddToScheme(scheme.Scheme)
+
+func Test_HubRunsOperator(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		labels       map[string]string
+		owners       []metav1.OwnerReference
+		pkg          string
+		runsOperator bool
+	}{
+		{
+			name:         "Foreign",
+			pkg:          "cluster-logging",
+			runsOperator: true,
+		},
+		{
+			name:   "Labeled",
+			labels: map[string]string{PartOfLabelKey: Name},
+			pkg:    "cluster-logging",
+		},
+		{
+			// Applied by a version of the addon that didn't label it yet
+			name: "AppliedByAddon",
+			owners: []metav1.OwnerReference{
+				{
+					APIVersion: "work.open-cluster-management.io/v1",
+					Kind:       "AppliedManifestWork",
+					Name:       "4b1f2c-addon-multicluster-observability-addon-deploy-0",
+					UID:        "1",
+				},
+			},
+			pkg: "cluster-logging",
+		},
+		{
+			name: "AppliedByOtherWork",
+			owners: []metav1.OwnerReference{
+				{
+					APIVersion: "work.open-cluster-management.io/v1",
+					Kind:       "AppliedManifestWork",
+					Name:       "4b1f2c-logging-stack",
+					UID:        "1",
+				},
+			},
+			pkg:          "cluster-logging",
+			runsOperator: true,
+		},
+		{
+			name: "OtherPackage",
+			pkg:  "loki-operator",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			subscription := &operatorsv1alpha1.Subscription{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "cluster-logging",
+					Namespace:       "openshift-logging",
+					Labels:          tc.labels,
+					OwnerReferences: tc.owners,
+				},
+				Spec: &operatorsv1alpha1.SubscriptionSpec{
+					Package: tc.pkg,
+				},
+			}
+			k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(subscription).Build()
+
+			runsOperator, err := HubRunsOperator(k8s, "cluster-logging")
+			require.NoError(t, err)
+			require.Equal(t, tc.runsOperator, runsOperator)
+		})
+	}
+}
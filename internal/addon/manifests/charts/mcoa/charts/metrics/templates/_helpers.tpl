@@ -15,3 +15,74 @@ chart: {{ template "metricshelm.chart" . }}
 release: {{ .Release.Name }}
 app.kubernetes.io/part-of: multicluster-observability-addon
 {{- end }}
+
+{{/* Render a list of relabel configs */}}
+{{- define "metricshelm.relabelConfigs" }}
+{{- range . }}
+- action: {{ .action | default "replace" | toJson }}
+  {{- with .sourceLabels }}
+  source_labels: {{ toJson . }}
+  {{- end }}
+  {{- with .separator }}
+  separator: {{ toJson . }}
+  {{- end }}
+  {{- with .regex }}
+  regex: {{ toJson . }}
+  {{- end }}
+  {{- with .modulus }}
+  modulus: {{ . }}
+  {{- end }}
+  {{- with .targetLabel }}
+  target_label: {{ toJson . }}
+  {{- end }}
+  {{- with .replacement }}
+  replacement: {{ toJson . }}
+  {{- end }}
+{{- end }}
+{{- end }}
+
+{{/*
+Render the scrape configs of the jobs federating the platform Prometheus and,
+when selectors are configured, the user workload Prometheus
+*/}}
+{{- define "metricshelm.federateScrapeConfigs" }}
+{{- $federation := .Values.federation }}
+{{- $jobs := list (dict "name" "federate" "target" "prometheus-k8s.openshift-monitoring.svc:9092" "matchers" $federation.matchers) }}
+{{- if $federation.userWorkloadMatchers }}
+{{- $jobs = append $jobs (dict "name" "federate-user-workload" "target" "prometheus-user-workload.openshift-user-workload-monitoring.svc:9092" "matchers" $federation.userWorkloadMatchers) }}
+{{- end }}
+{{- range $jobs }}
+- job_name: {{ .name | squote }}
+  scrape_interval: {{ $federation.scrapeInterval }}
+
+  honor_labels: true
+  {{- with $federation.sampleLimit }}
+  sample_limit: {{ . }}
+  {{- end }}
+  {{- with $federation.labelLimit }}
+  label_limit: {{ . }}
+  {{- end }}
+  metrics_path: '/federate'
+
+  scheme: https
+  tls_config:
+    ca_file: /etc/prometheus/configmaps/metrics-addon-agent-serving-certs-ca-bundle/service-ca.crt
+  bearer_token_file: /var/run/secrets/kubernetes.io/serviceaccount/token
+
+  params:
+    'match[]':
+      {{- range .matchers }}
+      - {{ . | toJson }}
+      {{- end }}
+
+  static_configs:
+    - targets:
+      - {{ .target | squote }}
+      labels:
+        prometheus_agent: "true"
+  {{- with $federation.metricRelabelConfigs }}
+  metric_relabel_configs:
+    {{- include "metricshelm.relabelConfigs" . | trim | nindent 4 }}
+  {{- end }}
+{{- end }}
+{{- end }}
@@ -0,0 +1,24 @@
+// Package v1 contains the subset of the observability.openshift.io/v1 API
+// group that is rendered by the addon for clusters running Logging 6.x.
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const ClusterLogForwarderKind = "ClusterLogForwarder"
+
+// GroupVersion is group version used to register these objects
+var GroupVersion = schema.GroupVersion{Group: "observability.openshift.io", Version: "v1"}
+
+// AddToScheme registers the ClusterLogForwarder kind of this group-version as
+// an unstructured object. This is enough for the addon-framework to decode the
+// manifests rendered by the logging chart without requiring generated
+// deepcopy functions for the types in this package.
+func AddToScheme(s *runtime.Scheme) error {
+	s.AddKnownTypeWithName(GroupVersion.WithKind(ClusterLogForwarderKind), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(GroupVersion.WithKind(ClusterLogForwarderKind+"List"), &unstructured.UnstructuredList{})
+	return nil
+}
@@ -0,0 +1,299 @@
+package v1
+
+import (
+	openshiftv1 "github.com/openshift/api/config/v1"
+	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The types in this file are a trimmed copy of the observability.openshift.io/v1
+// API shipped with Logging 6.x. The cluster-logging-operator module pinned in
+// go.mod predates this API group, so only the fields the addon renders are
+// kept here. They should be replaced by the upstream types once the module is
+// bumped.
+
+const (
+	OutputTypeCloudwatch         = "cloudwatch"
+	OutputTypeElasticsearch      = "elasticsearch"
+	OutputTypeGoogleCloudLogging = "googleCloudLogging"
+	OutputTypeHTTP               = "http"
+	OutputTypeKafka              = "kafka"
+	OutputTypeLoki               = "loki"
+	OutputTypeLokiStack          = "lokiStack"
+	OutputTypeSplunk             = "splunk"
+	OutputTypeSyslog             = "syslog"
+
+	InputTypeApplication    = "application"
+	InputTypeInfrastructure = "infrastructure"
+	InputTypeAudit          = "audit"
+	InputTypeReceiver       = "receiver"
+
+	FilterTypeDetectMultiline = "detectMultilineException"
+	FilterTypeKubeAPIAudit    = "kubeAPIAudit"
+	FilterTypeOpenshiftLabels = "openshiftLabels"
+	FilterTypeParse           = "parse"
+
+	CloudwatchAuthTypeAccessKey = "awsAccessKey"
+	CloudwatchAuthTypeIAMRole   = "iamRole"
+
+	BearerTokenFromSecret         = "secret"
+	BearerTokenFromServiceAccount = "serviceAccount"
+)
+
+// ClusterLogForwarderSpec defines how logs are collected and forwarded.
+type ClusterLogForwarderSpec struct {
+	ManagementState string         `json:"managementState,omitempty"`
+	ServiceAccount  ServiceAccount `json:"serviceAccount"`
+	Collector       *CollectorSpec `json:"collector,omitempty"`
+	Inputs          []InputSpec    `json:"inputs,omitempty"`
+	Outputs         []OutputSpec   `json:"outputs"`
+	Filters         []FilterSpec   `json:"filters,omitempty"`
+	Pipelines       []PipelineSpec `json:"pipelines"`
+}
+
+// ServiceAccount references the service account used by the collector. It must
+// be bound to the roles that allow collecting the requested log types.
+type ServiceAccount struct {
+	Name string `json:"name"`
+}
+
+// CollectorSpec defines scheduling and resources for the collector pods.
+type CollectorSpec struct {
+	Resources    *corev1.ResourceRequirements `json:"resources,omitempty"`
+	NodeSelector map[string]string            `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration          `json:"tolerations,omitempty"`
+}
+
+// InputSpec defines a selector of log messages.
+type InputSpec struct {
+	Name           string          `json:"name"`
+	Type           string          `json:"type"`
+	Application    *Application    `json:"application,omitempty"`
+	Infrastructure *Infrastructure `json:"infrastructure,omitempty"`
+	Audit          *Audit          `json:"audit,omitempty"`
+	Receiver       *ReceiverSpec   `json:"receiver,omitempty"`
+}
+
+type Application struct {
+	Includes []NamespaceContainerSpec `json:"includes,omitempty"`
+	Excludes []NamespaceContainerSpec `json:"excludes,omitempty"`
+	Selector *metav1.LabelSelector    `json:"selector,omitempty"`
+	Tuning   *ContainerInputTuning    `json:"tuning,omitempty"`
+}
+
+type NamespaceContainerSpec struct {
+	Namespace string `json:"namespace,omitempty"`
+	Container string `json:"container,omitempty"`
+}
+
+type ContainerInputTuning struct {
+	RateLimitPerContainer *LimitSpec `json:"rateLimitPerContainer,omitempty"`
+}
+
+type Infrastructure struct {
+	Sources []string `json:"sources"`
+}
+
+type Audit struct {
+	Sources []string `json:"sources"`
+}
+
+type ReceiverSpec struct {
+	Type string        `json:"type"`
+	Port int32         `json:"port"`
+	HTTP *HTTPReceiver `json:"http,omitempty"`
+}
+
+type HTTPReceiver struct {
+	Format string `json:"format"`
+}
+
+type LimitSpec struct {
+	MaxRecordsPerSecond int64 `json:"maxRecordsPerSecond"`
+}
+
+// OutputSpec defines a destination for log messages.
+type OutputSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	Cloudwatch         *Cloudwatch         `json:"cloudwatch,omitempty"`
+	Elasticsearch      *Elasticsearch      `json:"elasticsearch,omitempty"`
+	GoogleCloudLogging *GoogleCloudLogging `json:"googleCloudLogging,omitempty"`
+	HTTP               *HTTP               `json:"http,omitempty"`
+	Kafka              *Kafka              `json:"kafka,omitempty"`
+	Loki               *Loki               `json:"loki,omitempty"`
+	LokiStack          *LokiStack          `json:"lokiStack,omitempty"`
+	Splunk             *Splunk             `json:"splunk,omitempty"`
+	Syslog             *Syslog             `json:"syslog,omitempty"`
+
+	TLS       *OutputTLSSpec `json:"tls,omitempty"`
+	RateLimit *LimitSpec     `json:"rateLimit,omitempty"`
+}
+
+// OutputTLSSpec contains the TLS options and the references to the key
+// material used to connect to an output.
+type OutputTLSSpec struct {
+	CA                 *ValueReference                 `json:"ca,omitempty"`
+	Certificate        *ValueReference                 `json:"certificate,omitempty"`
+	Key                *SecretReference                `json:"key,omitempty"`
+	KeyPassphrase      *SecretReference                `json:"keyPassphrase,omitempty"`
+	InsecureSkipVerify bool                            `json:"insecureSkipVerify,omitempty"`
+	TLSSecurityProfile *openshiftv1.TLSSecurityProfile `json:"securityProfile,omitempty"`
+}
+
+// ValueReference points to a key in either a ConfigMap or a Secret.
+type ValueReference struct {
+	Key           string `json:"key"`
+	ConfigMapName string `json:"configMapName,omitempty"`
+	SecretName    string `json:"secretName,omitempty"`
+}
+
+// SecretReference points to a key in a Secret in the collector namespace.
+type SecretReference struct {
+	Key        string `json:"key"`
+	SecretName string `json:"secretName"`
+}
+
+type HTTPAuthentication struct {
+	Username *SecretReference `json:"username,omitempty"`
+	Password *SecretReference `json:"password,omitempty"`
+	Token    *BearerToken     `json:"token,omitempty"`
+}
+
+type BearerToken struct {
+	From   string          `json:"from"`
+	Secret *BearerTokenKey `json:"secret,omitempty"`
+}
+
+type BearerTokenKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+type Cloudwatch struct {
+	URL            string                    `json:"url,omitempty"`
+	Region         string                    `json:"region"`
+	GroupName      string                    `json:"groupName"`
+	Authentication *CloudwatchAuthentication `json:"authentication"`
+}
+
+type CloudwatchAuthentication struct {
+	Type         string                  `json:"type"`
+	AWSAccessKey *CloudwatchAWSAccessKey `json:"awsAccessKey,omitempty"`
+	IAMRole      *CloudwatchIAMRole      `json:"iamRole,omitempty"`
+}
+
+type CloudwatchAWSAccessKey struct {
+	KeyID     SecretReference `json:"keyId"`
+	KeySecret SecretReference `json:"keySecret"`
+}
+
+type CloudwatchIAMRole struct {
+	RoleARN SecretReference `json:"roleARN"`
+	Token   BearerToken     `json:"token"`
+}
+
+type Elasticsearch struct {
+	URL            string              `json:"url"`
+	Version        int                 `json:"version"`
+	Index          string              `json:"index"`
+	Authentication *HTTPAuthentication `json:"authentication,omitempty"`
+}
+
+type GoogleCloudLogging struct {
+	ID             GoogleCloudLoggingID              `json:"id"`
+	LogID          string                            `json:"logId"`
+	Authentication *GoogleCloudLoggingAuthentication `json:"authentication"`
+}
+
+type GoogleCloudLoggingID struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type GoogleCloudLoggingAuthentication struct {
+	Credentials *SecretReference `json:"credentials"`
+}
+
+type HTTP struct {
+	URL            string              `json:"url"`
+	Headers        map[string]string   `json:"headers,omitempty"`
+	Timeout        int                 `json:"timeout,omitempty"`
+	Method         string              `json:"method,omitempty"`
+	Authentication *HTTPAuthentication `json:"authentication,omitempty"`
+}
+
+type Kafka struct {
+	URL            string               `json:"url,omitempty"`
+	Topic          string               `json:"topic,omitempty"`
+	Brokers        []string             `json:"brokers,omitempty"`
+	Authentication *KafkaAuthentication `json:"authentication,omitempty"`
+}
+
+type KafkaAuthentication struct {
+	SASL *SASLAuthentication `json:"sasl,omitempty"`
+}
+
+type SASLAuthentication struct {
+	Username  *SecretReference `json:"username,omitempty"`
+	Password  *SecretReference `json:"password,omitempty"`
+	Mechanism string           `json:"mechanism,omitempty"`
+}
+
+type Loki struct {
+	URL            string              `json:"url"`
+	Authentication *HTTPAuthentication `json:"authentication,omitempty"`
+	LabelKeys      []string            `json:"labelKeys,omitempty"`
+	TenantKey      string              `json:"tenantKey,omitempty"`
+}
+
+type LokiStack struct {
+	Target         LokiStackTarget     `json:"target"`
+	Authentication *HTTPAuthentication `json:"authentication,omitempty"`
+	LabelKeys      []string            `json:"labelKeys,omitempty"`
+}
+
+type LokiStackTarget struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type Splunk struct {
+	URL            string                `json:"url"`
+	Index          string                `json:"index,omitempty"`
+	Authentication *SplunkAuthentication `json:"authentication"`
+}
+
+type SplunkAuthentication struct {
+	Token *SecretReference `json:"token"`
+}
+
+type Syslog struct {
+	URL        string `json:"url"`
+	RFC        string `json:"rfc"`
+	Severity   string `json:"severity,omitempty"`
+	Facility   string `json:"facility,omitempty"`
+	PayloadKey string `json:"payloadKey,omitempty"`
+	AppName    string `json:"appName,omitempty"`
+	ProcID     string `json:"procId,omitempty"`
+	MsgID      string `json:"msgId,omitempty"`
+	Enrichment string `json:"enrichment,omitempty"`
+}
+
+// FilterSpec defines a transformation applied to log messages of a pipeline.
+type FilterSpec struct {
+	Name            string                  `json:"name"`
+	Type            string                  `json:"type"`
+	KubeAPIAudit    *loggingv1.KubeAPIAudit `json:"kubeAPIAudit,omitempty"`
+	OpenshiftLabels map[string]string       `json:"openshiftLabels,omitempty"`
+}
+
+// PipelineSpec links a set of inputs to a set of outputs.
+type PipelineSpec struct {
+	Name       string   `json:"name"`
+	InputRefs  []string `json:"inputRefs"`
+	OutputRefs []string `json:"outputRefs"`
+	FilterRefs []string `json:"filterRefs,omitempty"`
+}
@@ -2,33 +2,67 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/ViaQ/logerr/v2/kverrors"
+	routev1 "github.com/openshift/api/route/v1"
 	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
 	"github.com/rhobs/multicluster-observability-addon/internal/addon"
 	"github.com/rhobs/multicluster-observability-addon/internal/addon/authentication"
 	"github.com/rhobs/multicluster-observability-addon/internal/logging/manifests"
 	corev1 "k8s.io/api/core/v1"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	clusterLogForwarderResource = "clusterlogforwarders"
+
+	// lokiStackGatewayValueKey references, as namespace/name, a LokiStack on
+	// the hub whose gateway is used by the Loki outputs without a URL
+	lokiStackGatewayValueKey = "loggingLokiStackGateway"
+
+	loggingOperatorPackage = "cluster-logging"
 )
 
-func BuildOptions(k8s client.Client, mcAddon *addonapiv1alpha1.ManagedClusterAddOn, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (manifests.Options, error) {
+func BuildOptions(k8s client.Client, cluster *clusterv1.ManagedCluster, mcAddon *addonapiv1alpha1.ManagedClusterAddOn, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (manifests.Options, error) {
 	resources := manifests.Options{
 		AddOnDeploymentConfig: adoc,
+		ManagedCluster:        cluster,
+	}
+
+	gatewayURL, err := discoverLokiStackGateway(k8s, cluster, adoc)
+	if err != nil {
+		return resources, err
 	}
+	resources.LokiStackGatewayURL = gatewayURL
 
-	key := addon.GetObjectKey(mcAddon.Status.ConfigReferences, loggingv1.GroupVersion.Group, clusterLogForwarderResource)
+	// The hub may already run the logging operator for its own stack
+	if addon.IsHubCluster(cluster) {
+		runsOperator, err := addon.HubRunsOperator(k8s, loggingOperatorPackage)
+		if err != nil {
+			return resources, err
+		}
+		resources.HubRunsOperator = runsOperator
+	}
+
+	key, overlayKey := getClusterLogForwarderKeys(mcAddon)
 	clf := &loggingv1.ClusterLogForwarder{}
 	if err := k8s.Get(context.Background(), key, clf, &client.GetOptions{}); err != nil {
 		return resources, err
 	}
 	resources.ClusterLogForwarder = clf
 
+	if overlayKey != nil {
+		overlay := &loggingv1.ClusterLogForwarder{}
+		if err := k8s.Get(context.Background(), *overlayKey, overlay, &client.GetOptions{}); err != nil {
+			return resources, err
+		}
+		resources.ClusterLogForwarderOverlay = overlay
+	}
+
 	authCM := &corev1.ConfigMap{}
 	caCM := &corev1.ConfigMap{}
 	for _, config := range mcAddon.Spec.Configs {
@@ -62,8 +96,10 @@ func BuildOptions(k8s client.Client, mcAddon *addonapiv1alpha1.ManagedClusterAdd
 	}
 
 	ctx := context.Background()
-	authConfig := manifests.AuthDefaultConfig
-	authConfig.MTLSConfig.CommonName = mcAddon.Namespace
+	authConfig, err := manifests.BuildAuthConfig(mcAddon.Namespace, resources)
+	if err != nil {
+		return resources, err
+	}
 	if len(caCM.Data) > 0 {
 		if ca, ok := caCM.Data["service-ca.crt"]; ok {
 			authConfig.MTLSConfig.CAToInject = ca
@@ -89,3 +125,82 @@ func BuildOptions(k8s client.Client, mcAddon *addonapiv1alpha1.ManagedClusterAdd
 
 	return resources, nil
 }
+
+// getClusterLogForwarderKeys returns the keys of the default
+// ClusterLogForwarder and of its overlay, if any. The overlay is a
+// ClusterLogForwarder referenced in the namespace of the managed cluster next
+// to the default one. Since the configs of the ManagedClusterAddOn replace the
+// defaults of the ClusterManagementAddOn for the same resource, both have to be
+// listed in the ManagedClusterAddOn. Being a referenced config, a change of the
+// overlay re-renders the manifests like a change of the default one.
+func getClusterLogForwarderKeys(mcAddon *addonapiv1alpha1.ManagedClusterAddOn) (client.ObjectKey, *client.ObjectKey) {
+	var (
+		key        client.ObjectKey
+		overlayKey *client.ObjectKey
+	)
+	for _, config := range mcAddon.Status.ConfigReferences {
+		if config.ConfigGroupResource.Group != loggingv1.GroupVersion.Group || config.ConfigGroupResource.Resource != clusterLogForwarderResource {
+			continue
+		}
+
+		ref := client.ObjectKey{Name: config.Name, Namespace: config.Namespace}
+		if ref.Namespace == mcAddon.Namespace && overlayKey == nil {
+			overlayKey = &ref
+			continue
+		}
+		if key.Name == "" {
+			key = ref
+		}
+	}
+
+	// A single ClusterLogForwarder in the namespace of the managed cluster is
+	// the default one
+	if key.Name == "" && overlayKey != nil {
+		return *overlayKey, nil
+	}
+	return key, overlayKey
+}
+
+// discoverLokiStackGateway returns the base URL of the tenants API exposed by
+// the gateway of the LokiStack referenced in the AddOnDeploymentConfig. The
+// gateway is reached through the Route created by the Loki operator with the
+// name of the LokiStack, or through the Service behind it from the hub
+// cluster. An empty URL is returned when no LokiStack is referenced.
+func discoverLokiStackGateway(k8s client.Client, cluster *clusterv1.ManagedCluster, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (string, error) {
+	if adoc == nil {
+		return "", nil
+	}
+
+	var ref string
+	for _, keyvalue := range adoc.Spec.CustomizedVariables {
+		if keyvalue.Name == lokiStackGatewayValueKey {
+			ref = keyvalue.Value
+			break
+		}
+	}
+	if ref == "" {
+		return "", nil
+	}
+
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", kverrors.New("invalid LokiStack reference, expected namespace/name", "name", lokiStackGatewayValueKey, "value", ref)
+	}
+
+	route := &routev1.Route{}
+	key := client.ObjectKey{Name: name, Namespace: namespace}
+	if err := k8s.Get(context.Background(), key, route, &client.GetOptions{}); err != nil {
+		return "", kverrors.Wrap(err, "failed to get LokiStack gateway route", "name", name, "namespace", namespace)
+	}
+
+	scheme, host := "https", route.Spec.Host
+	if addon.IsHubCluster(cluster) {
+		var err error
+		if host, err = addon.RouteServiceHost(k8s, route); err != nil {
+			return "", err
+		}
+		scheme = addon.RouteServiceScheme(route)
+	}
+
+	return fmt.Sprintf("%s://%s/api/logs/v1", scheme, host), nil
+}
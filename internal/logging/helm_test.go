@@ -5,15 +5,20 @@ import (
 
 	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
 	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	obsv1 "github.com/rhobs/multicluster-observability-addon/internal/logging/apis/observability/v1"
 	"github.com/rhobs/multicluster-observability-addon/internal/logging/handlers"
 	"github.com/rhobs/multicluster-observability-addon/internal/logging/manifests"
 
+	routev1 "github.com/openshift/api/route/v1"
 	loggingapis "github.com/openshift/cluster-logging-operator/apis"
 	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/ptr"
@@ -29,16 +34,18 @@ import (
 
 var (
 	_ = loggingapis.AddToScheme(scheme.Scheme)
+	_ = obsv1.AddToScheme(scheme.Scheme)
 	_ = operatorsv1.AddToScheme(scheme.Scheme)
 	_ = operatorsv1alpha1.AddToScheme(scheme.Scheme)
+	_ = routev1.AddToScheme(scheme.Scheme)
 )
 
-func fakeGetValues(k8s client.Client) addonfactory.GetValuesFunc {
+func fakeGetValues(k8s client.Client, adoc *addonapiv1alpha1.AddOnDeploymentConfig) addonfactory.GetValuesFunc {
 	return func(
 		cluster *clusterv1.ManagedCluster,
 		addon *addonapiv1alpha1.ManagedClusterAddOn,
 	) (addonfactory.Values, error) {
-		opts, err := handlers.BuildOptions(k8s, addon, nil)
+		opts, err := handlers.BuildOptions(k8s, cluster, addon, adoc)
 		if err != nil {
 			return nil, err
 		}
@@ -61,6 +68,7 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 		// Addon configuration
 		addOnDeploymentConfig *addonapiv1alpha1.AddOnDeploymentConfig
 		clf                   *loggingv1.ClusterLogForwarder
+		clfOverlay            *loggingv1.ClusterLogForwarder
 		authCM                *corev1.ConfigMap
 		staticCred            *corev1.Secret
 
@@ -107,6 +115,16 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 				Name:      "instance",
 			},
 		},
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    "logging.openshift.io",
+				Resource: "clusterlogforwarders",
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Namespace: "cluster-1",
+				Name:      "instance",
+			},
+		},
 	}
 
 	// Setup configuration resources: ClusterLogForwarder, AddOnDeploymentConfig, Secrets, ConfigMaps
@@ -165,14 +183,41 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 		},
 	}
 
+	// Overlay adding application logs of an extra namespace for cluster-1 only
+	clfOverlay = &loggingv1.ClusterLogForwarder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "instance",
+			Namespace: "cluster-1",
+		},
+		Spec: loggingv1.ClusterLogForwarderSpec{
+			Inputs: []loggingv1.InputSpec{
+				{
+					Name: "extra-app-logs",
+					Application: &loggingv1.Application{
+						Namespaces: []string{"ns-3"},
+					},
+				},
+			},
+			Pipelines: []loggingv1.PipelineSpec{
+				{
+					Name:       "extra-app-logs",
+					InputRefs:  []string{"extra-app-logs"},
+					OutputRefs: []string{"app-logs"},
+				},
+			},
+		},
+	}
+
 	staticCred = &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "static-authentication",
 			Namespace: "open-cluster-management",
 		},
 		Data: map[string][]byte{
-			"key":  []byte("data"),
-			"pass": []byte("data"),
+			"username":              []byte("data"),
+			"password":              []byte("data"),
+			"aws_access_key_id":     []byte("data"),
+			"aws_secret_access_key": []byte("data"),
 		},
 	}
 
@@ -201,6 +246,22 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 					Name:  "loggingSubscriptionChannel",
 					Value: "stable-5.8",
 				},
+				{
+					Name:  "loggingCollectorMemoryLimit",
+					Value: "2Gi",
+				},
+			},
+			NodePlacement: &addonapiv1alpha1.NodePlacement{
+				NodeSelector: map[string]string{
+					"node-role.kubernetes.io/infra": "",
+				},
+				Tolerations: []corev1.Toleration{
+					{
+						Key:      "node-role.kubernetes.io/infra",
+						Operator: corev1.TolerationOpExists,
+						Effect:   corev1.TaintEffectNoSchedule,
+					},
+				},
 			},
 		},
 	}
@@ -208,7 +269,7 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 	// Setup the fake k8s client
 	fakeKubeClient = fake.NewClientBuilder().
 		WithScheme(scheme.Scheme).
-		WithObjects(clf, staticCred, authCM).
+		WithObjects(clf, clfOverlay, staticCred, authCM).
 		Build()
 
 	// Setup the fake addon client
@@ -220,7 +281,7 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 
 	// Wire everything together to a fake addon instance
 	loggingAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, addon.LoggingChartDir).
-		WithGetValuesFuncs(addonConfigValuesFn, fakeGetValues(fakeKubeClient)).
+		WithGetValuesFuncs(addonConfigValuesFn, fakeGetValues(fakeKubeClient, addOnDeploymentConfig)).
 		WithAgentRegistrationOption(&agent.RegistrationOption{}).
 		WithScheme(scheme.Scheme).
 		BuildHelmAgentAddon()
@@ -237,11 +298,20 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 		switch obj := obj.(type) {
 		case *operatorsv1alpha1.Subscription:
 			require.Equal(t, obj.Spec.Channel, "stable-5.8")
+		case *loggingv1.ClusterLogging:
+			require.Equal(t, loggingv1.LogCollectionTypeVector, obj.Spec.Collection.Type)
+			require.Equal(t, addOnDeploymentConfig.Spec.NodePlacement.NodeSelector, obj.Spec.Collection.NodeSelector)
+			require.Equal(t, addOnDeploymentConfig.Spec.NodePlacement.Tolerations, obj.Spec.Collection.Tolerations)
+			require.Equal(t, "2Gi", obj.Spec.Collection.Resources.Limits.Memory().String())
 		case *loggingv1.ClusterLogForwarder:
 			require.NotNil(t, obj.Spec.Outputs[0].Secret)
 			require.NotNil(t, obj.Spec.Outputs[1].Secret)
 			require.Equal(t, "logging-app-logs-auth", obj.Spec.Outputs[0].Secret.Name)
 			require.Equal(t, "logging-cluster-logs-auth", obj.Spec.Outputs[1].Secret.Name)
+			require.Len(t, obj.Spec.Inputs, 3)
+			require.Equal(t, "extra-app-logs", obj.Spec.Inputs[2].Name)
+			require.Len(t, obj.Spec.Pipelines, 3)
+			require.Equal(t, "extra-app-logs", obj.Spec.Pipelines[2].Name)
 		case *corev1.Secret:
 			if obj.Name == "logging-app-logs-auth" {
 				require.Equal(t, staticCred.Data, obj.Data)
@@ -253,4 +323,280 @@ func Test_Logging_AllConfigsTogether_AllResources(t *testing.T) {
 
 		}
 	}
+
+	// An overlay that isn't referenced isn't merged, the manifests wouldn't be
+	// rendered again on its changes
+	managedClusterAddOn.Status.ConfigReferences = managedClusterAddOn.Status.ConfigReferences[:2]
+	objects, err = loggingAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+	for _, obj := range objects {
+		if obj, ok := obj.(*loggingv1.ClusterLogForwarder); ok {
+			require.Len(t, obj.Spec.Inputs, 2)
+			require.Len(t, obj.Spec.Pipelines, 2)
+		}
+	}
+}
+
+func Test_Logging_ObservabilityAPI_AllResources(t *testing.T) {
+	var (
+		// Addon envinronment and registration
+		managedCluster      *clusterv1.ManagedCluster
+		managedClusterAddOn *addonapiv1alpha1.ManagedClusterAddOn
+
+		// Addon configuration
+		clf        *loggingv1.ClusterLogForwarder
+		authCM     *corev1.ConfigMap
+		staticCred *corev1.Secret
+
+		// Test clients
+		fakeKubeClient client.Client
+	)
+
+	// Setup a managed cluster that reports running Logging 6.x
+	managedCluster = addontesting.NewManagedCluster("cluster-1")
+	managedCluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{
+		{
+			Name:  "version.logging.openshift.io",
+			Value: "6.0.1",
+		},
+	}
+
+	// Register the addon for the managed cluster
+	managedClusterAddOn = addontesting.NewAddon("test", "cluster-1")
+	managedClusterAddOn.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    "",
+				Resource: "configmaps",
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Namespace: "open-cluster-management",
+				Name:      "logging-auth",
+			},
+		},
+	}
+	managedClusterAddOn.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    "logging.openshift.io",
+				Resource: "clusterlogforwarders",
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Namespace: "open-cluster-management",
+				Name:      "instance",
+			},
+		},
+	}
+
+	// Setup configuration resources: ClusterLogForwarder, Secrets, ConfigMaps
+	clf = &loggingv1.ClusterLogForwarder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "instance",
+			Namespace: "open-cluster-management",
+		},
+		Spec: loggingv1.ClusterLogForwarderSpec{
+			Outputs: []loggingv1.OutputSpec{
+				{
+					Name: "app-logs",
+					Type: loggingv1.OutputTypeLoki,
+					URL:  "https://loki.example.com",
+				},
+			},
+			Pipelines: []loggingv1.PipelineSpec{
+				{
+					Name:       "app-logs",
+					InputRefs:  []string{loggingv1.InputNameApplication},
+					OutputRefs: []string{"app-logs"},
+				},
+			},
+		},
+	}
+
+	staticCred = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "static-authentication",
+			Namespace: "open-cluster-management",
+		},
+		Data: map[string][]byte{
+			"username": []byte("user"),
+			"password": []byte("pass"),
+		},
+	}
+
+	authCM = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "logging-auth",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				"mcoa.openshift.io/signal": "logging",
+			},
+		},
+		Data: map[string]string{
+			"app-logs": "StaticAuthentication",
+		},
+	}
+
+	// The subscription channel follows the logging version of the cluster
+	addOnDeploymentConfig := &addonapiv1alpha1.AddOnDeploymentConfig{
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "loggingSubscriptionChannel", Value: "stable-6.0"},
+			},
+		},
+	}
+
+	// Setup the fake k8s client
+	fakeKubeClient = fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(clf, staticCred, authCM).
+		Build()
+
+	// Wire everything together to a fake addon instance
+	loggingAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, addon.LoggingChartDir).
+		WithGetValuesFuncs(fakeGetValues(fakeKubeClient, addOnDeploymentConfig)).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(scheme.Scheme).
+		BuildHelmAgentAddon()
+	if err != nil {
+		klog.Fatalf("failed to build agent %v", err)
+	}
+
+	// Render manifests and return them as k8s runtime objects
+	objects, err := loggingAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+	require.Equal(t, 9, len(objects))
+
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *loggingv1.ClusterLogging, *loggingv1.ClusterLogForwarder:
+			require.Fail(t, "unexpected logging.openshift.io/v1 resource")
+		case *corev1.ServiceAccount:
+			require.Equal(t, "mcoa-logcollector", obj.Name)
+		case *rbacv1.ClusterRoleBinding:
+			require.Equal(t, "mcoa-logcollector", obj.Subjects[0].Name)
+		case *unstructured.Unstructured:
+			require.Equal(t, "observability.openshift.io/v1", obj.GetAPIVersion())
+			sa, _, err := unstructured.NestedString(obj.Object, "spec", "serviceAccount", "name")
+			require.NoError(t, err)
+			require.Equal(t, "mcoa-logcollector", sa)
+
+			outputs, _, err := unstructured.NestedSlice(obj.Object, "spec", "outputs")
+			require.NoError(t, err)
+			require.Len(t, outputs, 1)
+			username, _, err := unstructured.NestedString(outputs[0].(map[string]interface{}), "loki", "authentication", "username", "secretName")
+			require.NoError(t, err)
+			require.Equal(t, "logging-app-logs-auth", username)
+		}
+	}
+}
+
+func Test_Logging_HubCluster(t *testing.T) {
+	// The hub manages itself and already runs the logging operator
+	managedCluster := addontesting.NewManagedCluster("local-cluster")
+	managedCluster.Labels = map[string]string{"local-cluster": "true"}
+
+	managedClusterAddOn := addontesting.NewAddon("test", "local-cluster")
+	managedClusterAddOn.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    "logging.openshift.io",
+				Resource: "clusterlogforwarders",
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Namespace: "open-cluster-management",
+				Name:      "instance",
+			},
+		},
+	}
+
+	clf := &loggingv1.ClusterLogForwarder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "instance",
+			Namespace: "open-cluster-management",
+		},
+		Spec: loggingv1.ClusterLogForwarderSpec{
+			Outputs: []loggingv1.OutputSpec{
+				{
+					Name: "app-logs",
+					Type: loggingv1.OutputTypeLoki,
+				},
+			},
+			Pipelines: []loggingv1.PipelineSpec{
+				{
+					Name:       "app-logs",
+					InputRefs:  []string{loggingv1.InputNameApplication},
+					OutputRefs: []string{"app-logs"},
+				},
+			},
+		},
+	}
+	subscription := &operatorsv1alpha1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-logging",
+			Namespace: "openshift-logging",
+		},
+		Spec: &operatorsv1alpha1.SubscriptionSpec{
+			Package: "cluster-logging",
+		},
+	}
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "logging-loki",
+			Namespace: "openshift-logging",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "logging-loki.apps.hub.example.com",
+			To:   routev1.RouteTargetReference{Kind: "Service", Name: "logging-loki-gateway-http"},
+			Port: &routev1.RoutePort{TargetPort: intstr.FromString("public")},
+			TLS:  &routev1.TLSConfig{Termination: routev1.TLSTerminationReencrypt},
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "logging-loki-gateway-http",
+			Namespace: "openshift-logging",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "metrics", Port: 8081},
+				{Name: "public", Port: 8080},
+			},
+		},
+	}
+	adoc := &addonapiv1alpha1.AddOnDeploymentConfig{
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "loggingLokiStackGateway", Value: "openshift-logging/logging-loki"},
+			},
+		},
+	}
+
+	fakeKubeClient := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(clf, subscription, route, service).
+		Build()
+
+	loggingAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, addon.LoggingChartDir).
+		WithGetValuesFuncs(fakeGetValues(fakeKubeClient, adoc)).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(scheme.Scheme).
+		BuildHelmAgentAddon()
+	require.NoError(t, err)
+
+	objects, err := loggingAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+
+	var forwarder *loggingv1.ClusterLogForwarder
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *operatorsv1alpha1.Subscription, *operatorsv1.OperatorGroup:
+			require.Fail(t, "unexpected operator installation on the hub")
+		case *corev1.Namespace:
+			require.Fail(t, "unexpected operator namespace on the hub", obj.Name)
+		case *loggingv1.ClusterLogForwarder:
+			forwarder = obj
+		}
+	}
+	require.NotNil(t, forwarder)
+	require.Equal(t, "https://logging-loki-gateway-http.openshift-logging.svc:8080/api/logs/v1/application", forwarder.Spec.Outputs[0].URL)
 }
@@ -0,0 +1,55 @@
+package manifests
+
+import (
+	"github.com/ViaQ/logerr/v2/kverrors"
+	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// buildCollectorSpec returns the scheduling and resources of the collector
+// pods. Node selector and tolerations are taken from the NodePlacement of the
+// AddOnDeploymentConfig while resources are set with customized variables.
+// It returns nil when nothing is configured so that the operator defaults
+// apply.
+func buildCollectorSpec(resources Options) (*loggingv1.CollectorSpec, error) {
+	collector := &loggingv1.CollectorSpec{}
+
+	if adoc := resources.AddOnDeploymentConfig; adoc != nil && adoc.Spec.NodePlacement != nil {
+		collector.NodeSelector = adoc.Spec.NodePlacement.NodeSelector
+		collector.Tolerations = adoc.Spec.NodePlacement.Tolerations
+	}
+
+	requirements := corev1.ResourceRequirements{}
+	for _, r := range []struct {
+		key  string
+		name corev1.ResourceName
+		list *corev1.ResourceList
+	}{
+		{key: collectorCPURequestValueKey, name: corev1.ResourceCPU, list: &requirements.Requests},
+		{key: collectorMemoryRequestValueKey, name: corev1.ResourceMemory, list: &requirements.Requests},
+		{key: collectorCPULimitValueKey, name: corev1.ResourceCPU, list: &requirements.Limits},
+		{key: collectorMemoryLimitValueKey, name: corev1.ResourceMemory, list: &requirements.Limits},
+	} {
+		value, ok := customizedVariable(resources, r.key)
+		if !ok {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, kverrors.Wrap(err, "invalid customized variable", "name", r.key, "value", value)
+		}
+		if *r.list == nil {
+			*r.list = corev1.ResourceList{}
+		}
+		(*r.list)[r.name] = quantity
+	}
+	if len(requirements.Requests) > 0 || len(requirements.Limits) > 0 {
+		collector.Resources = &requirements
+	}
+
+	if collector.Resources == nil && len(collector.NodeSelector) == 0 && len(collector.Tolerations) == 0 {
+		return nil, nil
+	}
+	return collector, nil
+}
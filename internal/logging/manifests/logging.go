@@ -2,25 +2,119 @@ package manifests
 
 import (
 	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/ViaQ/logerr/v2/kverrors"
 	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	obsv1 "github.com/rhobs/multicluster-observability-addon/internal/logging/apis/observability/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
-func buildSubscriptionChannel(resources Options) string {
+var (
+	versionRegexp    = regexp.MustCompile(`(\d+)\.(\d+)`)
+	lokiLabelReplace = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// customizedVariable returns the value of the AddOnDeploymentConfig
+// customized variable with the given name.
+func customizedVariable(resources Options, name string) (string, bool) {
 	adoc := resources.AddOnDeploymentConfig
-	if adoc == nil || len(adoc.Spec.CustomizedVariables) == 0 {
-		return defaultLoggingVersion
+	if adoc == nil {
+		return "", false
 	}
 
 	for _, keyvalue := range adoc.Spec.CustomizedVariables {
-		if keyvalue.Name == subscriptionChannelValueKey {
-			return keyvalue.Value
+		if keyvalue.Name == name {
+			return keyvalue.Value, true
 		}
 	}
+	return "", false
+}
+
+func buildSubscriptionChannel(resources Options) string {
+	if channel, ok := customizedVariable(resources, subscriptionChannelValueKey); ok {
+		return channel
+	}
 	return defaultLoggingVersion
 }
 
+// BuildNamespace returns the namespace where the logging operator and the
+// collector are installed on the spoke cluster.
+func BuildNamespace(resources Options) (string, error) {
+	namespace, ok := customizedVariable(resources, namespaceValueKey)
+	if !ok || namespace == "" {
+		return defaultNamespace, nil
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", kverrors.New("invalid customized variable", "name", namespaceValueKey, "value", namespace, "reason", strings.Join(errs, ", "))
+	}
+	return namespace, nil
+}
+
+// collectorServiceName returns the name of the Service of the collector. The
+// logging operator names the collector of the ClusterLogForwarder
+// openshift-logging/instance of logging.openshift.io/v1 after the
+// ClusterLogging, other collectors are named after their ClusterLogForwarder.
+func collectorServiceName(namespace, clfAPIVersion string) string {
+	if namespace == defaultNamespace && clfAPIVersion == loggingv1.GroupVersion.String() {
+		return legacyCollectorName
+	}
+	return clusterLogForwarderName
+}
+
+// buildCLFAPIVersion returns the ClusterLogForwarder API version supported by
+// the logging operator on the spoke cluster. The version reported by the
+// cluster through a ClusterClaim is used when the subscription channel isn't
+// versioned. A claim and a channel for operators with different APIs are
+// rejected, the subscription would install an operator that doesn't support
+// the rendered ClusterLogForwarder.
+func buildCLFAPIVersion(resources Options) (string, error) {
+	channel := buildSubscriptionChannel(resources)
+	apiVersion := clfAPIVersionOf(channel)
+	if resources.ManagedCluster == nil {
+		return apiVersion, nil
+	}
+
+	for _, claim := range resources.ManagedCluster.Status.ClusterClaims {
+		if claim.Name != clusterClaimLoggingVersion {
+			continue
+		}
+		claimAPIVersion := clfAPIVersionOf(claim.Value)
+		if majorVersion(channel) > 0 && claimAPIVersion != apiVersion {
+			return "", kverrors.New("the logging version of the cluster doesn't match the subscription channel",
+				"claim", claim.Value, "channel", channel)
+		}
+		return claimAPIVersion, nil
+	}
+	return apiVersion, nil
+}
+
+func clfAPIVersionOf(version string) string {
+	if majorVersion(version) >= observabilityAPIMajorVersion {
+		return obsv1.GroupVersion.String()
+	}
+	return loggingv1.GroupVersion.String()
+}
+
+// majorVersion extracts the major version from strings such as "stable-5.8"
+// or "6.0.1". It returns 0 when no version can be found.
+func majorVersion(version string) int {
+	matches := versionRegexp.FindStringSubmatch(version)
+	if matches == nil {
+		return 0
+	}
+	major, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
 func buildSecrets(resources Options) ([]SecretValue, error) {
 	secretsValue := []SecretValue{}
 	for _, secret := range resources.Secrets {
@@ -39,6 +133,10 @@ func buildSecrets(resources Options) ([]SecretValue, error) {
 
 func buildClusterLogForwarderSpec(resources Options) (*loggingv1.ClusterLogForwarderSpec, error) {
 	clf := resources.ClusterLogForwarder
+	if overlay := resources.ClusterLogForwarderOverlay; overlay != nil {
+		clf.Spec = mergeClusterLogForwarderSpec(clf.Spec, overlay.Spec)
+	}
+
 	for _, secret := range resources.Secrets {
 		if err := templateWithSecret(&clf.Spec, secret); err != nil {
 			return nil, err
@@ -51,27 +149,130 @@ func buildClusterLogForwarderSpec(resources Options) (*loggingv1.ClusterLogForwa
 		}
 	}
 
+	if err := templateWithLokiStackGateway(&clf.Spec, resources.LokiStackGatewayURL); err != nil {
+		return nil, err
+	}
+
+	if err := injectClusterLabels(&clf.Spec, resources); err != nil {
+		return nil, err
+	}
+
 	return &clf.Spec, nil
 }
 
+// buildClusterLabels returns the labels that identify the managed cluster in
+// the log streams: its name, its ID and the ManagedCluster labels selected in
+// the AddOnDeploymentConfig. Label names are sanitized to be valid Loki labels.
+func buildClusterLabels(resources Options) map[string]string {
+	cluster := resources.ManagedCluster
+	labels := map[string]string{
+		lokiLabelClusterName: cluster.Name,
+	}
+	if id, ok := addon.ClusterID(cluster); ok {
+		labels[lokiLabelClusterID] = id
+	}
+
+	selected, _ := customizedVariable(resources, clusterLabelsValueKey)
+	for _, key := range strings.Split(selected, ",") {
+		key = strings.TrimSpace(key)
+		value, ok := cluster.Labels[key]
+		if key == "" || !ok {
+			continue
+		}
+		labels[lokiLabelReplace.ReplaceAllString(key, "_")] = value
+	}
+
+	return labels
+}
+
+// injectClusterLabels adds the labels identifying the managed cluster to the
+// records of every pipeline forwarding to a Loki output and uses them as
+// stream labels of those outputs. When enabled, the cluster name is also used
+// as tenant for the Loki outputs that don't set a tenant key.
+func injectClusterLabels(spec *loggingv1.ClusterLogForwarderSpec, resources Options) error {
+	if resources.ManagedCluster == nil {
+		return nil
+	}
+
+	clusterTenant := false
+	if value, ok := customizedVariable(resources, lokiClusterTenantValueKey); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return kverrors.Wrap(err, "invalid customized variable", "name", lokiClusterTenantValueKey, "value", value)
+		}
+		clusterTenant = enabled
+	}
+
+	labels := buildClusterLabels(resources)
+	labelNames := make([]string, 0, len(labels))
+	for name := range labels {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+
+	lokiOutputs := map[string]bool{}
+	for k, output := range spec.Outputs {
+		if output.Type != loggingv1.OutputTypeLoki {
+			continue
+		}
+		lokiOutputs[output.Name] = true
+
+		if output.Loki == nil {
+			output.Loki = &loggingv1.Loki{}
+		}
+		if len(output.Loki.LabelKeys) == 0 {
+			output.Loki.LabelKeys = append([]string{}, defaultLokiLabelKeys...)
+		}
+		for _, name := range labelNames {
+			output.Loki.LabelKeys = appendUnique(output.Loki.LabelKeys, openshiftLabelsPrefix+name)
+		}
+		if clusterTenant && output.Loki.TenantKey == "" {
+			output.Loki.TenantKey = openshiftLabelsPrefix + lokiLabelClusterName
+		}
+		spec.Outputs[k] = output
+	}
+
+	for k, pipeline := range spec.Pipelines {
+		if !referencesAny(pipeline.OutputRefs, lokiOutputs) {
+			continue
+		}
+		if pipeline.Labels == nil {
+			pipeline.Labels = map[string]string{}
+		}
+		// Labels set explicitly on the pipeline take precedence
+		for name, value := range labels {
+			if _, ok := pipeline.Labels[name]; !ok {
+				pipeline.Labels[name] = value
+			}
+		}
+		spec.Pipelines[k] = pipeline
+	}
+
+	return nil
+}
+
 func templateWithSecret(spec *loggingv1.ClusterLogForwarderSpec, secret corev1.Secret) error {
 	clfOutputName, ok := secret.Annotations[AnnotationTargetOutputName]
 	if !ok {
 		return nil
 	}
-	// TODO(JoaoBraveCoding) Validate that clfOutputName actually exists
-	// TODO(JoaoBraveCoding) Validate secret
 
 	for k, output := range spec.Outputs {
-		if output.Name == clfOutputName {
-			output.Secret = &loggingv1.OutputSecretSpec{
-				Name: secret.Name,
-			}
-			spec.Outputs[k] = output
+		if output.Name != clfOutputName {
+			continue
 		}
+
+		if err := validateOutputSecret(output, secret); err != nil {
+			return err
+		}
+		output.Secret = &loggingv1.OutputSecretSpec{
+			Name: secret.Name,
+		}
+		spec.Outputs[k] = output
+		return nil
 	}
 
-	return nil
+	return kverrors.New("secret references an output that does not exist", "output", clfOutputName, "secret", secret.Name)
 }
 
 func templateWithConfigMap(spec *loggingv1.ClusterLogForwarderSpec, configmap corev1.ConfigMap) error {
@@ -81,11 +282,52 @@ func templateWithConfigMap(spec *loggingv1.ClusterLogForwarderSpec, configmap co
 	}
 
 	for k, output := range spec.Outputs {
-		if output.Name == clfOutputName && output.Type == "loki" {
-			output.URL = configmap.Data["url"]
-			spec.Outputs[k] = output
+		if output.Name != clfOutputName {
+			continue
+		}
+
+		fields, ok := outputConfigMapFields[output.Type]
+		if !ok {
+			return kverrors.New("output type does not support templating", "output", output.Name, "type", output.Type)
+		}
+
+		// Iterate in a stable order to always report the same error
+		keys := make([]string, 0, len(configmap.Data))
+		for key := range configmap.Data {
+			keys = append(keys, key)
 		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			setField, ok := fields[key]
+			if !ok {
+				return kverrors.New("configmap key does not apply to output type", "key", key, "configmap", configmap.Name, "output", output.Name, "type", output.Type)
+			}
+			if err := setField(&output, configmap.Data[key]); err != nil {
+				return kverrors.Wrap(err, "failed to template output", "key", key, "configmap", configmap.Name, "output", output.Name)
+			}
+		}
+		spec.Outputs[k] = output
+		return nil
 	}
 
-	return nil
+	return kverrors.New("configmap references an output that does not exist", "output", clfOutputName, "configmap", configmap.Name)
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func referencesAny(refs []string, names map[string]bool) bool {
+	for _, ref := range refs {
+		if names[ref] {
+			return true
+		}
+	}
+	return false
 }
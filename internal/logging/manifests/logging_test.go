@@ -9,10 +9,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 )
 
 func Test_BuildSubscriptionChannel(t *testing.T) {
@@ -55,6 +57,71 @@ func Test_BuildSubscriptionChannel(t *testing.T) {
 	}
 }
 
+func Test_BuildNamespace(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		vars      []addonapiv1alpha1.CustomizedVariable
+		namespace string
+		dnsName   string
+		wantErr   bool
+	}{
+		{
+			name: "unknown key",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "test", Value: "my-logging"},
+			},
+			namespace: "openshift-logging",
+			dnsName:   "collector.openshift-logging.svc",
+		},
+		{
+			name: "known key",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "loggingNamespace", Value: "my-logging"},
+			},
+			namespace: "my-logging",
+			dnsName:   "instance.my-logging.svc",
+		},
+		{
+			name: "logging 6",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "loggingSubscriptionChannel", Value: "stable-6.0"},
+			},
+			namespace: "openshift-logging",
+			dnsName:   "instance.openshift-logging.svc",
+		},
+		{
+			name: "invalid namespace",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "loggingNamespace", Value: "My_Logging"},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resources := Options{
+				AddOnDeploymentConfig: &addonapiv1alpha1.AddOnDeploymentConfig{
+					Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+						CustomizedVariables: tc.vars,
+					},
+				},
+			}
+			namespace, err := BuildNamespace(resources)
+			if tc.wantErr {
+				require.Error(t, err)
+				_, err = BuildAuthConfig("cluster-1", resources)
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.namespace, namespace)
+
+			authConfig, err := BuildAuthConfig("cluster-1", resources)
+			require.NoError(t, err)
+			require.Equal(t, []string{tc.dnsName}, authConfig.MTLSConfig.DNSNames)
+		})
+	}
+}
+
 func Test_BuildSecrets(t *testing.T) {
 	resources := Options{
 		Secrets: []corev1.Secret{
@@ -228,8 +295,8 @@ func Test_BuildCLFSpec(t *testing.T) {
 			},
 		},
 		Data: map[string][]byte{
-			"tls.crt": []byte("cert"),
-			"tls.key": []byte("key"),
+			"aws_access_key_id":     []byte("key-id"),
+			"aws_secret_access_key": []byte("key-secret"),
 		},
 	}
 
@@ -278,6 +345,10 @@ func Test_TemplateWithSecret(t *testing.T) {
 						"logging.mcoa.openshift.io/target-output-name": "foo",
 					},
 				},
+				Data: map[string][]byte{
+					"tls.crt": []byte("cert"),
+					"tls.key": []byte("key"),
+				},
 			}
 
 			if tc.wrongTargetAnnotationValue {
@@ -290,15 +361,17 @@ func Test_TemplateWithSecret(t *testing.T) {
 				Outputs: []loggingv1.OutputSpec{
 					{
 						Name: "foo",
+						Type: loggingv1.OutputTypeLoki,
 					},
 				},
 			}
 
 			err := templateWithSecret(spec, *secret)
-			assert.NoError(t, err)
 			if tc.wrongTargetAnnotationValue {
+				assert.Error(t, err)
 				assert.Nil(t, spec.Outputs[0].Secret)
 			} else {
+				assert.NoError(t, err)
 				assert.NotNil(t, spec.Outputs[0].Secret)
 				assert.Equal(t, tc.expectedSecretName, spec.Outputs[0].Secret.Name)
 			}
@@ -306,6 +379,110 @@ func Test_TemplateWithSecret(t *testing.T) {
 	}
 }
 
+func Test_ValidateOutputSecret(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		outputType string
+		data       map[string][]byte
+		wantErr    bool
+	}{
+		{
+			name:       "LokiMTLS",
+			outputType: loggingv1.OutputTypeLoki,
+			data: map[string][]byte{
+				"tls.crt":       []byte("cert"),
+				"tls.key":       []byte("key"),
+				"ca-bundle.crt": []byte("ca"),
+			},
+		},
+		{
+			name:       "LokiMissingTLSKey",
+			outputType: loggingv1.OutputTypeLoki,
+			data: map[string][]byte{
+				"tls.crt":       []byte("cert"),
+				"ca-bundle.crt": []byte("ca"),
+			},
+			wantErr: true,
+		},
+		{
+			name:       "ElasticsearchBasicAuth",
+			outputType: loggingv1.OutputTypeElasticsearch,
+			data: map[string][]byte{
+				"username": []byte("user"),
+				"password": []byte("pass"),
+			},
+		},
+		{
+			name:       "HTTPMissingPassword",
+			outputType: loggingv1.OutputTypeHttp,
+			data: map[string][]byte{
+				"username": []byte("user"),
+			},
+			wantErr: true,
+		},
+		{
+			name:       "CloudwatchAccessKey",
+			outputType: loggingv1.OutputTypeCloudwatch,
+			data: map[string][]byte{
+				"aws_access_key_id":     []byte("key-id"),
+				"aws_secret_access_key": []byte("key-secret"),
+			},
+		},
+		{
+			name:       "CloudwatchRoleARN",
+			outputType: loggingv1.OutputTypeCloudwatch,
+			data: map[string][]byte{
+				"role_arn": []byte("arn"),
+			},
+		},
+		{
+			name:       "CloudwatchWithoutCredentials",
+			outputType: loggingv1.OutputTypeCloudwatch,
+			data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+			},
+			wantErr: true,
+		},
+		{
+			name:       "SplunkHECToken",
+			outputType: loggingv1.OutputTypeSplunk,
+			data: map[string][]byte{
+				"hecToken": []byte("token"),
+			},
+		},
+		{
+			name:       "UnknownOutputType",
+			outputType: "foo",
+			data: map[string][]byte{
+				"token": []byte("token"),
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			output := loggingv1.OutputSpec{
+				Name: "output",
+				Type: tc.outputType,
+			}
+			secret := corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "output-secret",
+					Namespace: "cluster-1",
+				},
+				Data: tc.data,
+			}
+
+			err := validateOutputSecret(output, secret)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
 func Test_TemplateWithConfigMap(t *testing.T) {
 	for _, tc := range []struct {
 		name                       string
@@ -356,8 +533,662 @@ func Test_TemplateWithConfigMap(t *testing.T) {
 			}
 
 			err := templateWithConfigMap(spec, *cm)
-			assert.NoError(t, err, "Expected no error")
+			if tc.wrongTargetAnnotationValue {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err, "Expected no error")
+			}
 			assert.Equal(t, tc.expectedCLFUrl, spec.Outputs[0].URL)
 		})
 	}
 }
+
+func Test_BuildCLFAPIVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		subChannel string
+		claims     []clusterv1.ManagedClusterClaim
+		apiVersion string
+		wantErr    bool
+	}{
+		{
+			name:       "default channel",
+			apiVersion: "logging.openshift.io/v1",
+		},
+		{
+			name:       "logging 6 channel",
+			subChannel: "stable-6.0",
+			apiVersion: "observability.openshift.io/v1",
+		},
+		{
+			name:       "unversioned channel",
+			subChannel: "stable",
+			apiVersion: "logging.openshift.io/v1",
+		},
+		{
+			name:       "claim of an unversioned channel",
+			subChannel: "stable",
+			claims: []clusterv1.ManagedClusterClaim{
+				{Name: "version.logging.openshift.io", Value: "6.0.1"},
+			},
+			apiVersion: "observability.openshift.io/v1",
+		},
+		{
+			name:       "claim matching the channel",
+			subChannel: "stable-6.1",
+			claims: []clusterv1.ManagedClusterClaim{
+				{Name: "version.logging.openshift.io", Value: "6.0.2"},
+			},
+			apiVersion: "observability.openshift.io/v1",
+		},
+		{
+			name:       "logging 5 claim with a logging 6 channel",
+			subChannel: "stable-6.0",
+			claims: []clusterv1.ManagedClusterClaim{
+				{Name: "version.logging.openshift.io", Value: "5.9.4"},
+			},
+			wantErr: true,
+		},
+		{
+			name:       "logging 6 claim with a logging 5 channel",
+			subChannel: "stable-5.9",
+			claims: []clusterv1.ManagedClusterClaim{
+				{Name: "version.logging.openshift.io", Value: "6.1.0"},
+			},
+			wantErr: true,
+		},
+		{
+			// The default channel installs logging 5
+			name: "logging 6 claim with the default channel",
+			claims: []clusterv1.ManagedClusterClaim{
+				{Name: "version.logging.openshift.io", Value: "6.1.0"},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resources := Options{
+				ManagedCluster: addontesting.NewManagedCluster("cluster-1"),
+			}
+			resources.ManagedCluster.Status.ClusterClaims = tc.claims
+			if tc.subChannel != "" {
+				resources.AddOnDeploymentConfig = &addonapiv1alpha1.AddOnDeploymentConfig{
+					Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+						CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+							{
+								Name:  "loggingSubscriptionChannel",
+								Value: tc.subChannel,
+							},
+						},
+					},
+				}
+			}
+			apiVersion, err := buildCLFAPIVersion(resources)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.apiVersion, apiVersion)
+		})
+	}
+}
+
+func Test_BuildObservabilityCLFSpec(t *testing.T) {
+	spec := &loggingv1.ClusterLogForwarderSpec{
+		Inputs: []loggingv1.InputSpec{
+			{
+				Name: "app-logs",
+				Application: &loggingv1.Application{
+					Namespaces: []string{"ns-1"},
+				},
+			},
+		},
+		Outputs: []loggingv1.OutputSpec{
+			{
+				Name: "app-logs",
+				Type: loggingv1.OutputTypeLoki,
+				URL:  "https://loki.example.com",
+				OutputTypeSpec: loggingv1.OutputTypeSpec{
+					Loki: &loggingv1.Loki{
+						TenantKey: "kubernetes.namespace_name",
+					},
+				},
+				Secret: &loggingv1.OutputSecretSpec{Name: "logging-app-logs-auth"},
+			},
+			{
+				Name: "cluster-logs",
+				Type: loggingv1.OutputTypeCloudwatch,
+				OutputTypeSpec: loggingv1.OutputTypeSpec{
+					Cloudwatch: &loggingv1.Cloudwatch{
+						Region:      "us-east-1",
+						GroupBy:     loggingv1.LogGroupByLogType,
+						GroupPrefix: pointer.String("test-prefix"),
+					},
+				},
+				Secret: &loggingv1.OutputSecretSpec{Name: "logging-cluster-logs-auth"},
+			},
+		},
+		Pipelines: []loggingv1.PipelineSpec{
+			{
+				Name:       "app-logs",
+				InputRefs:  []string{"app-logs"},
+				OutputRefs: []string{"app-logs"},
+				Labels:     map[string]string{"foo": "bar"},
+			},
+			{
+				InputRefs:  []string{loggingv1.InputNameInfrastructure},
+				OutputRefs: []string{"cluster-logs"},
+			},
+		},
+	}
+	secrets := []corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "logging-app-logs-auth"},
+			Data: map[string][]byte{
+				"tls.crt":       []byte("cert"),
+				"tls.key":       []byte("key"),
+				"ca-bundle.crt": []byte("ca"),
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "logging-cluster-logs-auth"},
+			Data: map[string][]byte{
+				"aws_access_key_id":     []byte("id"),
+				"aws_secret_access_key": []byte("secret"),
+			},
+		},
+	}
+
+	obsSpec, err := buildObservabilityCLFSpec(spec, secrets, "collector")
+	require.NoError(t, err)
+	require.Equal(t, "collector", obsSpec.ServiceAccount.Name)
+
+	require.Equal(t, "application", obsSpec.Inputs[0].Type)
+	require.Equal(t, "ns-1", obsSpec.Inputs[0].Application.Includes[0].Namespace)
+
+	loki := obsSpec.Outputs[0]
+	require.Equal(t, "https://loki.example.com", loki.Loki.URL)
+	require.Equal(t, `{.kubernetes.namespace_name||"none"}`, loki.Loki.TenantKey)
+	require.Nil(t, loki.Loki.Authentication)
+	require.Equal(t, "logging-app-logs-auth", loki.TLS.Certificate.SecretName)
+	require.Equal(t, "tls.key", loki.TLS.Key.Key)
+	require.Equal(t, "ca-bundle.crt", loki.TLS.CA.Key)
+
+	cw := obsSpec.Outputs[1]
+	require.Equal(t, `test-prefix.{.log_type||"none"}`, cw.Cloudwatch.GroupName)
+	require.Equal(t, "awsAccessKey", cw.Cloudwatch.Authentication.Type)
+	require.Equal(t, "aws_access_key_id", cw.Cloudwatch.Authentication.AWSAccessKey.KeyID.Key)
+	require.Nil(t, cw.TLS)
+
+	require.Equal(t, []string{"app-logs-labels"}, obsSpec.Pipelines[0].FilterRefs)
+	require.Equal(t, "openshiftLabels", obsSpec.Filters[0].Type)
+	require.Equal(t, "pipeline-1", obsSpec.Pipelines[1].Name)
+
+	// Outputs referencing secrets not generated by the addon can't be converted
+	_, err = buildObservabilityCLFSpec(spec, secrets[:1], "collector")
+	require.Error(t, err)
+}
+
+func Test_TemplateWithConfigMap_OutputTypes(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		outputType string
+		data       map[string]string
+		expected   loggingv1.OutputSpec
+		wantErr    bool
+	}{
+		{
+			name:       "elasticsearch",
+			outputType: loggingv1.OutputTypeElasticsearch,
+			data:       map[string]string{"url": "https://es.example.com", "version": "7"},
+			expected: loggingv1.OutputSpec{
+				URL: "https://es.example.com",
+				OutputTypeSpec: loggingv1.OutputTypeSpec{
+					Elasticsearch: &loggingv1.Elasticsearch{Version: 7},
+				},
+			},
+		},
+		{
+			name:       "kafka",
+			outputType: loggingv1.OutputTypeKafka,
+			data:       map[string]string{"topic": "logs", "brokers": "tls://b-1:9093, tls://b-2:9093"},
+			expected: loggingv1.OutputSpec{
+				OutputTypeSpec: loggingv1.OutputTypeSpec{
+					Kafka: &loggingv1.Kafka{Topic: "logs", Brokers: []string{"tls://b-1:9093", "tls://b-2:9093"}},
+				},
+			},
+		},
+		{
+			name:       "cloudwatch",
+			outputType: loggingv1.OutputTypeCloudwatch,
+			data:       map[string]string{"region": "eu-west-1", "groupPrefix": "cluster-1", "groupBy": "namespaceName"},
+			expected: loggingv1.OutputSpec{
+				OutputTypeSpec: loggingv1.OutputTypeSpec{
+					Cloudwatch: &loggingv1.Cloudwatch{
+						Region:      "eu-west-1",
+						GroupBy:     loggingv1.LogGroupByNamespaceName,
+						GroupPrefix: pointer.String("cluster-1"),
+					},
+				},
+			},
+		},
+		{
+			name:       "syslog",
+			outputType: loggingv1.OutputTypeSyslog,
+			data:       map[string]string{"url": "tcp://syslog:514", "rfc": "RFC3164"},
+			expected: loggingv1.OutputSpec{
+				URL: "tcp://syslog:514",
+				OutputTypeSpec: loggingv1.OutputTypeSpec{
+					Syslog: &loggingv1.Syslog{RFC: "RFC3164"},
+				},
+			},
+		},
+		{
+			name:       "key not applicable to output type",
+			outputType: loggingv1.OutputTypeSplunk,
+			data:       map[string]string{"url": "https://splunk:8088", "region": "eu-west-1"},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid value",
+			outputType: loggingv1.OutputTypeCloudwatch,
+			data:       map[string]string{"groupBy": "pod"},
+			wantErr:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-1",
+					Namespace: "cluster-1",
+					Annotations: map[string]string{
+						"logging.mcoa.openshift.io/target-output-name": "foo",
+					},
+				},
+				Data: tc.data,
+			}
+
+			spec := &loggingv1.ClusterLogForwarderSpec{
+				Outputs: []loggingv1.OutputSpec{
+					{
+						Name: "foo",
+						Type: tc.outputType,
+					},
+				},
+			}
+
+			err := templateWithConfigMap(spec, cm)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			tc.expected.Name = "foo"
+			tc.expected.Type = tc.outputType
+			require.Equal(t, tc.expected, spec.Outputs[0])
+		})
+	}
+}
+
+func Test_InjectClusterLabels(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster-1")
+	cluster.Labels = map[string]string{
+		"clusterID": "1234",
+		"region":    "eu-west-1",
+		"cluster.open-cluster-management.io/clusterset": "default",
+		"environment": "prod",
+	}
+
+	resources := Options{
+		ManagedCluster: cluster,
+		AddOnDeploymentConfig: &addonapiv1alpha1.AddOnDeploymentConfig{
+			Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+				CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+					{
+						Name:  "loggingClusterLabels",
+						Value: "region, cluster.open-cluster-management.io/clusterset,missing",
+					},
+					{
+						Name:  "loggingLokiClusterTenant",
+						Value: "true",
+					},
+				},
+			},
+		},
+	}
+
+	spec := &loggingv1.ClusterLogForwarderSpec{
+		Outputs: []loggingv1.OutputSpec{
+			{
+				Name: "app-logs",
+				Type: loggingv1.OutputTypeLoki,
+			},
+			{
+				Name: "audit-logs",
+				Type: loggingv1.OutputTypeLoki,
+				OutputTypeSpec: loggingv1.OutputTypeSpec{
+					Loki: &loggingv1.Loki{
+						LabelKeys: []string{"log_type"},
+						TenantKey: "kubernetes.namespace_name",
+					},
+				},
+			},
+			{
+				Name: "cluster-logs",
+				Type: loggingv1.OutputTypeCloudwatch,
+			},
+		},
+		Pipelines: []loggingv1.PipelineSpec{
+			{
+				Name:       "app-logs",
+				InputRefs:  []string{loggingv1.InputNameApplication},
+				OutputRefs: []string{"app-logs"},
+				Labels: map[string]string{
+					"region": "custom",
+				},
+			},
+			{
+				Name:       "audit-logs",
+				InputRefs:  []string{loggingv1.InputNameAudit},
+				OutputRefs: []string{"audit-logs"},
+			},
+			{
+				Name:       "cluster-logs",
+				InputRefs:  []string{loggingv1.InputNameInfrastructure},
+				OutputRefs: []string{"cluster-logs"},
+			},
+		},
+	}
+
+	err := injectClusterLabels(spec, resources)
+	require.NoError(t, err)
+
+	clusterLabelKeys := []string{
+		"openshift.labels.cluster_id",
+		"openshift.labels.cluster_name",
+		"openshift.labels.cluster_open_cluster_management_io_clusterset",
+		"openshift.labels.region",
+	}
+	require.Equal(t, append(append([]string{}, defaultLokiLabelKeys...), clusterLabelKeys...), spec.Outputs[0].Loki.LabelKeys)
+	require.Equal(t, "openshift.labels.cluster_name", spec.Outputs[0].Loki.TenantKey)
+	require.Equal(t, append([]string{"log_type"}, clusterLabelKeys...), spec.Outputs[1].Loki.LabelKeys)
+	require.Equal(t, "kubernetes.namespace_name", spec.Outputs[1].Loki.TenantKey)
+	require.Nil(t, spec.Outputs[2].Loki)
+
+	require.Equal(t, map[string]string{
+		"cluster_name": "cluster-1",
+		"cluster_id":   "1234",
+		"cluster_open_cluster_management_io_clusterset": "default",
+		"region": "custom",
+	}, spec.Pipelines[0].Labels)
+	require.Equal(t, "eu-west-1", spec.Pipelines[1].Labels["region"])
+	require.Nil(t, spec.Pipelines[2].Labels)
+}
+
+func Test_BuildClusterLabels_ClusterIDClaim(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster-1")
+	cluster.Labels = map[string]string{"clusterID": "label-id"}
+	cluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{
+		{Name: "id.k8s.io", Value: "claim-id"},
+	}
+
+	// The ID reported by the cluster is the one the metrics use too
+	labels := buildClusterLabels(Options{ManagedCluster: cluster})
+	require.Equal(t, map[string]string{
+		"cluster_name": "cluster-1",
+		"cluster_id":   "claim-id",
+	}, labels)
+}
+
+func Test_InjectClusterLabels_InvalidTenantVariable(t *testing.T) {
+	resources := Options{
+		ManagedCluster: addontesting.NewManagedCluster("cluster-1"),
+		AddOnDeploymentConfig: &addonapiv1alpha1.AddOnDeploymentConfig{
+			Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+				CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+					{
+						Name:  "loggingLokiClusterTenant",
+						Value: "yes please",
+					},
+				},
+			},
+		},
+	}
+
+	err := injectClusterLabels(&loggingv1.ClusterLogForwarderSpec{}, resources)
+	require.Error(t, err)
+}
+
+func Test_MergeClusterLogForwarderSpec(t *testing.T) {
+	base := loggingv1.ClusterLogForwarderSpec{
+		Inputs: []loggingv1.InputSpec{
+			{
+				Name: "app-logs",
+				Application: &loggingv1.Application{
+					Namespaces: []string{"ns-1"},
+				},
+			},
+		},
+		Outputs: []loggingv1.OutputSpec{
+			{
+				Name: "app-logs",
+				Type: loggingv1.OutputTypeLoki,
+				URL:  "https://loki.example.com",
+			},
+		},
+		Pipelines: []loggingv1.PipelineSpec{
+			{
+				Name:       "app-logs",
+				InputRefs:  []string{"app-logs"},
+				OutputRefs: []string{"app-logs"},
+			},
+		},
+	}
+
+	overlay := loggingv1.ClusterLogForwarderSpec{
+		Inputs: []loggingv1.InputSpec{
+			{
+				Name: "app-logs",
+				Application: &loggingv1.Application{
+					Namespaces: []string{"ns-1", "ns-2"},
+				},
+			},
+			{
+				Name:           "infra-logs",
+				Infrastructure: &loggingv1.Infrastructure{},
+			},
+		},
+		Filters: []loggingv1.FilterSpec{
+			{
+				Name: "audit-policy",
+				Type: loggingv1.FilterKubeAPIAudit,
+			},
+		},
+		Pipelines: []loggingv1.PipelineSpec{
+			{
+				Name:       "infra-logs",
+				InputRefs:  []string{"infra-logs"},
+				OutputRefs: []string{"app-logs"},
+			},
+			{
+				InputRefs:  []string{loggingv1.InputNameAudit},
+				OutputRefs: []string{"app-logs"},
+				FilterRefs: []string{"audit-policy"},
+			},
+		},
+	}
+
+	merged := mergeClusterLogForwarderSpec(base, overlay)
+
+	require.Len(t, merged.Inputs, 2)
+	require.Equal(t, []string{"ns-1", "ns-2"}, merged.Inputs[0].Application.Namespaces)
+	require.Equal(t, "infra-logs", merged.Inputs[1].Name)
+	require.Equal(t, base.Outputs, merged.Outputs)
+	require.Equal(t, overlay.Filters, merged.Filters)
+	require.Len(t, merged.Pipelines, 3)
+	require.Equal(t, "app-logs", merged.Pipelines[0].Name)
+	require.Equal(t, "infra-logs", merged.Pipelines[1].Name)
+	require.Equal(t, []string{"audit-policy"}, merged.Pipelines[2].FilterRefs)
+
+	// The default spec is left untouched
+	require.Equal(t, []string{"ns-1"}, base.Inputs[0].Application.Namespaces)
+	require.Len(t, base.Pipelines, 1)
+}
+
+func Test_MergeByName(t *testing.T) {
+	type item struct{ name, value string }
+	name := func(i item) string { return i.name }
+
+	base := []item{{"a", "base"}, {"", "unnamed"}, {"b", "base"}}
+	overlay := []item{{"b", "overlay"}, {"c", "first"}, {"", "unnamed"}, {"c", "second"}}
+
+	merged := mergeByName(base, overlay, name)
+	require.Equal(t, []item{{"a", "base"}, {"", "unnamed"}, {"b", "overlay"}, {"c", "second"}, {"", "unnamed"}}, merged)
+
+	// The items of base are left untouched
+	require.Equal(t, []item{{"a", "base"}, {"", "unnamed"}, {"b", "base"}}, base)
+}
+
+func Test_TemplateWithLokiStackGateway(t *testing.T) {
+	gatewayURL := "https://lokistack-hub.apps.example.com/api/logs/v1"
+
+	for _, tc := range []struct {
+		name        string
+		output      loggingv1.OutputSpec
+		inputRefs   []string
+		expectedURL string
+		wantErr     bool
+	}{
+		{
+			name:        "ApplicationTenant",
+			output:      loggingv1.OutputSpec{Name: "loki", Type: loggingv1.OutputTypeLoki},
+			inputRefs:   []string{"app-logs"},
+			expectedURL: gatewayURL + "/application",
+		},
+		{
+			name:        "ReservedInputName",
+			output:      loggingv1.OutputSpec{Name: "loki", Type: loggingv1.OutputTypeLoki},
+			inputRefs:   []string{loggingv1.InputNameAudit},
+			expectedURL: gatewayURL + "/audit",
+		},
+		{
+			name:        "SameTenantInputs",
+			output:      loggingv1.OutputSpec{Name: "loki", Type: loggingv1.OutputTypeLoki},
+			inputRefs:   []string{"infra-logs", loggingv1.InputNameInfrastructure},
+			expectedURL: gatewayURL + "/infrastructure",
+		},
+		{
+			name:        "URLFromConfigMap",
+			output:      loggingv1.OutputSpec{Name: "loki", Type: loggingv1.OutputTypeLoki, URL: "https://loki.example.com"},
+			inputRefs:   []string{"app-logs"},
+			expectedURL: "https://loki.example.com",
+		},
+		{
+			name:        "NotLokiOutput",
+			output:      loggingv1.OutputSpec{Name: "loki", Type: loggingv1.OutputTypeHttp},
+			inputRefs:   []string{"app-logs"},
+			expectedURL: "",
+		},
+		{
+			name:      "SeveralTenants",
+			output:    loggingv1.OutputSpec{Name: "loki", Type: loggingv1.OutputTypeLoki},
+			inputRefs: []string{"app-logs", "infra-logs"},
+			wantErr:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &loggingv1.ClusterLogForwarderSpec{
+				Inputs: []loggingv1.InputSpec{
+					{
+						Name:        "app-logs",
+						Application: &loggingv1.Application{},
+					},
+					{
+						Name:           "infra-logs",
+						Infrastructure: &loggingv1.Infrastructure{},
+					},
+				},
+				Outputs: []loggingv1.OutputSpec{tc.output},
+				Pipelines: []loggingv1.PipelineSpec{
+					{
+						Name:       "loki",
+						InputRefs:  tc.inputRefs,
+						OutputRefs: []string{"loki"},
+					},
+				},
+			}
+
+			err := templateWithLokiStackGateway(spec, gatewayURL)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedURL, spec.Outputs[0].URL)
+		})
+	}
+}
+
+func Test_BuildCollectorSpec(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		adoc      *addonapiv1alpha1.AddOnDeploymentConfig
+		collector *loggingv1.CollectorSpec
+		wantErr   bool
+	}{
+		{
+			name: "NotConfigured",
+			adoc: &addonapiv1alpha1.AddOnDeploymentConfig{},
+		},
+		{
+			name: "NodePlacementAndResources",
+			adoc: &addonapiv1alpha1.AddOnDeploymentConfig{
+				Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+					CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+						{Name: "loggingCollectorCPURequest", Value: "500m"},
+						{Name: "loggingCollectorMemoryLimit", Value: "1Gi"},
+					},
+					NodePlacement: &addonapiv1alpha1.NodePlacement{
+						NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+						Tolerations: []corev1.Toleration{
+							{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists},
+						},
+					},
+				},
+			},
+			collector: &loggingv1.CollectorSpec{
+				Resources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+				},
+				NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+				Tolerations: []corev1.Toleration{
+					{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists},
+				},
+			},
+		},
+		{
+			name: "InvalidQuantity",
+			adoc: &addonapiv1alpha1.AddOnDeploymentConfig{
+				Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+					CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+						{Name: "loggingCollectorMemoryRequest", Value: "lots"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			collector, err := buildCollectorSpec(Options{AddOnDeploymentConfig: tc.adoc})
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.collector, collector)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package manifests
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+)
+
+// templateWithLokiStackGateway sets the URL of the Loki outputs that weren't
+// templated by a ConfigMap to the LokiStack gateway endpoint of the tenant of
+// the logs they receive. Since the LokiStack gateway stores application,
+// infrastructure and audit logs in different tenants, an output receiving
+// logs of more than one tenant can't be templated.
+func templateWithLokiStackGateway(spec *loggingv1.ClusterLogForwarderSpec, gatewayURL string) error {
+	if gatewayURL == "" {
+		return nil
+	}
+
+	for k, output := range spec.Outputs {
+		if output.Type != loggingv1.OutputTypeLoki || output.URL != "" {
+			continue
+		}
+
+		tenants := outputTenants(spec, output.Name)
+		switch len(tenants) {
+		case 0:
+			continue
+		case 1:
+			output.URL = fmt.Sprintf("%s/%s", gatewayURL, tenants[0])
+			spec.Outputs[k] = output
+		default:
+			return kverrors.New("output receives logs of several LokiStack tenants, use one output per tenant", "output", output.Name, "tenants", tenants)
+		}
+	}
+
+	return nil
+}
+
+// outputTenants returns the LokiStack tenants of the logs forwarded to the
+// output by the pipelines referencing it.
+func outputTenants(spec *loggingv1.ClusterLogForwarderSpec, outputName string) []string {
+	inputTenants := map[string]string{
+		loggingv1.InputNameApplication:    lokiStackTenantApplication,
+		loggingv1.InputNameInfrastructure: lokiStackTenantInfrastructure,
+		loggingv1.InputNameAudit:          lokiStackTenantAudit,
+	}
+	for _, input := range spec.Inputs {
+		switch {
+		case input.Application != nil:
+			inputTenants[input.Name] = lokiStackTenantApplication
+		case input.Infrastructure != nil:
+			inputTenants[input.Name] = lokiStackTenantInfrastructure
+		case input.Audit != nil:
+			inputTenants[input.Name] = lokiStackTenantAudit
+		}
+	}
+
+	found := map[string]bool{}
+	for _, pipeline := range spec.Pipelines {
+		if !referencesAny(pipeline.OutputRefs, map[string]bool{outputName: true}) {
+			continue
+		}
+		for _, ref := range pipeline.InputRefs {
+			if tenant, ok := inputTenants[ref]; ok {
+				found[tenant] = true
+			}
+		}
+	}
+
+	tenants := make([]string, 0, len(found))
+	for tenant := range found {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
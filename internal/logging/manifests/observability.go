@@ -0,0 +1,427 @@
+package manifests
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	obsv1 "github.com/rhobs/multicluster-observability-addon/internal/logging/apis/observability/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Keys used by the logging.openshift.io/v1 API to look up credentials in an
+// output secret.
+const (
+	secretKeyTLSCert         = "tls.crt"
+	secretKeyTLSKey          = "tls.key"
+	secretKeyCABundle        = "ca-bundle.crt"
+	secretKeyPassphrase      = "passphrase"
+	secretKeyUsername        = "username"
+	secretKeyPassword        = "password"
+	secretKeyToken           = "token"
+	secretKeySASLMechanism   = "sasl.mechanisms"
+	secretKeyAWSAccessKeyID  = "aws_access_key_id"
+	secretKeyAWSSecretKey    = "aws_secret_access_key"
+	secretKeyAWSRoleARN      = "role_arn"
+	secretKeyAWSCredentials  = "credentials"
+	secretKeySplunkHECToken  = "hecToken"
+	secretKeyGoogleCloudCred = "google-application-credentials.json"
+	secretKeySharedKey       = "shared_key"
+)
+
+var (
+	infrastructureSources = []string{"container", "node"}
+	auditSources          = []string{"auditd", "kubeAPI", "openshiftAPI", "ovn"}
+)
+
+// outputSecret gives access to the keys of the secret referenced by an output
+// so that the matching authentication references can be rendered.
+type outputSecret struct {
+	name string
+	data map[string][]byte
+}
+
+func (s *outputSecret) has(key string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.data[key]
+	return ok
+}
+
+func (s *outputSecret) ref(key string) *obsv1.SecretReference {
+	if !s.has(key) {
+		return nil
+	}
+	return &obsv1.SecretReference{Key: key, SecretName: s.name}
+}
+
+// buildObservabilityCLFSpec converts a templated logging.openshift.io/v1
+// ClusterLogForwarder spec to the observability.openshift.io/v1 API used by
+// Logging 6.x. Authentication that was implicit in the v1 API, through well
+// known keys in the output secret, is made explicit using the keys present in
+// the secrets generated by the addon.
+func buildObservabilityCLFSpec(spec *loggingv1.ClusterLogForwarderSpec, secrets []corev1.Secret, serviceAccount string) (*obsv1.ClusterLogForwarderSpec, error) {
+	obsSpec := &obsv1.ClusterLogForwarderSpec{
+		ManagementState: string(loggingv1.ManagementStateManaged),
+		ServiceAccount:  obsv1.ServiceAccount{Name: serviceAccount},
+		Outputs:         []obsv1.OutputSpec{},
+		Pipelines:       []obsv1.PipelineSpec{},
+	}
+
+	for _, input := range spec.Inputs {
+		obsInput, err := convertInput(input)
+		if err != nil {
+			return nil, err
+		}
+		obsSpec.Inputs = append(obsSpec.Inputs, obsInput)
+	}
+
+	secretsByName := make(map[string]*outputSecret, len(secrets))
+	for _, secret := range secrets {
+		secretsByName[secret.Name] = &outputSecret{name: secret.Name, data: secret.Data}
+	}
+
+	for _, output := range spec.Outputs {
+		var secret *outputSecret
+		if output.Secret != nil {
+			s, ok := secretsByName[output.Secret.Name]
+			if !ok {
+				return nil, kverrors.New("output secret is not managed by the addon", "output", output.Name, "secret", output.Secret.Name)
+			}
+			secret = s
+		}
+
+		obsOutput, err := convertOutput(output, secret)
+		if err != nil {
+			return nil, err
+		}
+		obsSpec.Outputs = append(obsSpec.Outputs, obsOutput)
+	}
+
+	for _, filter := range spec.Filters {
+		obsSpec.Filters = append(obsSpec.Filters, obsv1.FilterSpec{
+			Name:         filter.Name,
+			Type:         filter.Type,
+			KubeAPIAudit: filter.KubeAPIAudit,
+		})
+	}
+
+	for i, pipeline := range spec.Pipelines {
+		name := pipeline.Name
+		if name == "" {
+			name = fmt.Sprintf("pipeline-%d", i)
+		}
+
+		obsPipeline := obsv1.PipelineSpec{
+			Name:       name,
+			InputRefs:  pipeline.InputRefs,
+			OutputRefs: pipeline.OutputRefs,
+			FilterRefs: pipeline.FilterRefs,
+		}
+
+		// Pipeline level features of the v1 API are filters in the
+		// observability API
+		if pipeline.DetectMultilineErrors {
+			filterName := fmt.Sprintf("%s-multiline", name)
+			obsSpec.Filters = append(obsSpec.Filters, obsv1.FilterSpec{Name: filterName, Type: obsv1.FilterTypeDetectMultiline})
+			obsPipeline.FilterRefs = append(obsPipeline.FilterRefs, filterName)
+		}
+		if pipeline.Parse == "json" {
+			filterName := fmt.Sprintf("%s-parse", name)
+			obsSpec.Filters = append(obsSpec.Filters, obsv1.FilterSpec{Name: filterName, Type: obsv1.FilterTypeParse})
+			obsPipeline.FilterRefs = append(obsPipeline.FilterRefs, filterName)
+		}
+		if len(pipeline.Labels) > 0 {
+			filterName := fmt.Sprintf("%s-labels", name)
+			obsSpec.Filters = append(obsSpec.Filters, obsv1.FilterSpec{Name: filterName, Type: obsv1.FilterTypeOpenshiftLabels, OpenshiftLabels: pipeline.Labels})
+			obsPipeline.FilterRefs = append(obsPipeline.FilterRefs, filterName)
+		}
+
+		obsSpec.Pipelines = append(obsSpec.Pipelines, obsPipeline)
+	}
+
+	return obsSpec, nil
+}
+
+func convertInput(input loggingv1.InputSpec) (obsv1.InputSpec, error) {
+	obsInput := obsv1.InputSpec{Name: input.Name}
+	switch {
+	case input.Application != nil:
+		app := &obsv1.Application{}
+		for _, ns := range input.Application.Namespaces {
+			app.Includes = append(app.Includes, obsv1.NamespaceContainerSpec{Namespace: ns})
+		}
+		if input.Application.Selector != nil {
+			app.Selector = &metav1.LabelSelector{MatchLabels: input.Application.Selector.MatchLabels}
+		}
+		if input.Application.ContainerLimit != nil {
+			app.Tuning = &obsv1.ContainerInputTuning{
+				RateLimitPerContainer: &obsv1.LimitSpec{MaxRecordsPerSecond: input.Application.ContainerLimit.MaxRecordsPerSecond},
+			}
+		}
+		obsInput.Type = obsv1.InputTypeApplication
+		obsInput.Application = app
+	case input.Infrastructure != nil:
+		obsInput.Type = obsv1.InputTypeInfrastructure
+		obsInput.Infrastructure = &obsv1.Infrastructure{Sources: infrastructureSources}
+	case input.Audit != nil:
+		obsInput.Type = obsv1.InputTypeAudit
+		obsInput.Audit = &obsv1.Audit{Sources: auditSources}
+	case input.Receiver != nil:
+		receiver := &obsv1.ReceiverSpec{Type: input.Receiver.Type}
+		if input.Receiver.ReceiverTypeSpec != nil {
+			if http := input.Receiver.HTTP; http != nil {
+				receiver.Port = http.Port
+				receiver.HTTP = &obsv1.HTTPReceiver{Format: http.Format}
+			}
+			if syslog := input.Receiver.Syslog; syslog != nil {
+				receiver.Port = syslog.Port
+			}
+		}
+		obsInput.Type = obsv1.InputTypeReceiver
+		obsInput.Receiver = receiver
+	default:
+		return obsInput, kverrors.New("input does not select any type of logs", "input", input.Name)
+	}
+
+	return obsInput, nil
+}
+
+func convertOutput(output loggingv1.OutputSpec, secret *outputSecret) (obsv1.OutputSpec, error) {
+	obsOutput := obsv1.OutputSpec{
+		Name: output.Name,
+		TLS:  convertTLS(output, secret),
+	}
+	if output.Limit != nil {
+		obsOutput.RateLimit = &obsv1.LimitSpec{MaxRecordsPerSecond: output.Limit.MaxRecordsPerSecond}
+	}
+
+	switch output.Type {
+	case loggingv1.OutputTypeLoki:
+		loki := &obsv1.Loki{
+			URL:            output.URL,
+			Authentication: httpAuthentication(secret),
+		}
+		if output.Loki != nil {
+			loki.LabelKeys = output.Loki.LabelKeys
+			loki.TenantKey = fieldTemplate(output.Loki.TenantKey)
+		}
+		obsOutput.Type = obsv1.OutputTypeLoki
+		obsOutput.Loki = loki
+
+	case loggingv1.OutputTypeElasticsearch:
+		es := &obsv1.Elasticsearch{
+			URL:            output.URL,
+			Version:        defaultElasticsearchVersion,
+			Index:          fieldTemplate("log_type"),
+			Authentication: httpAuthentication(secret),
+		}
+		if output.Elasticsearch != nil {
+			if output.Elasticsearch.Version != 0 {
+				es.Version = output.Elasticsearch.Version
+			}
+			if key := output.Elasticsearch.StructuredTypeKey; key != "" {
+				es.Index = fieldTemplate(key)
+			} else if name := output.Elasticsearch.StructuredTypeName; name != "" {
+				es.Index = name
+			}
+		}
+		obsOutput.Type = obsv1.OutputTypeElasticsearch
+		obsOutput.Elasticsearch = es
+
+	case loggingv1.OutputTypeKafka:
+		kafka := &obsv1.Kafka{URL: output.URL}
+		if output.Kafka != nil {
+			kafka.Topic = output.Kafka.Topic
+			kafka.Brokers = output.Kafka.Brokers
+		}
+		if secret.has(secretKeyUsername) || secret.has(secretKeyPassword) {
+			kafka.Authentication = &obsv1.KafkaAuthentication{
+				SASL: &obsv1.SASLAuthentication{
+					Username:  secret.ref(secretKeyUsername),
+					Password:  secret.ref(secretKeyPassword),
+					Mechanism: string(secret.data[secretKeySASLMechanism]),
+				},
+			}
+		}
+		obsOutput.Type = obsv1.OutputTypeKafka
+		obsOutput.Kafka = kafka
+
+	case loggingv1.OutputTypeSplunk:
+		splunk := &obsv1.Splunk{URL: output.URL}
+		if secret.has(secretKeySplunkHECToken) {
+			splunk.Authentication = &obsv1.SplunkAuthentication{Token: secret.ref(secretKeySplunkHECToken)}
+		}
+		obsOutput.Type = obsv1.OutputTypeSplunk
+		obsOutput.Splunk = splunk
+
+	case loggingv1.OutputTypeHttp:
+		http := &obsv1.HTTP{
+			URL:            output.URL,
+			Authentication: httpAuthentication(secret),
+		}
+		if output.Http != nil {
+			http.Headers = output.Http.Headers
+			http.Timeout = output.Http.Timeout
+			http.Method = output.Http.Method
+		}
+		obsOutput.Type = obsv1.OutputTypeHTTP
+		obsOutput.HTTP = http
+
+	case loggingv1.OutputTypeSyslog:
+		syslog := &obsv1.Syslog{
+			URL: output.URL,
+			RFC: defaultSyslogRFC,
+		}
+		if s := output.Syslog; s != nil {
+			if s.RFC != "" {
+				syslog.RFC = s.RFC
+			}
+			syslog.Severity = s.Severity
+			syslog.Facility = s.Facility
+			syslog.PayloadKey = s.PayloadKey
+			syslog.AppName = s.AppName
+			syslog.ProcID = s.ProcID
+			syslog.MsgID = s.MsgID
+			if s.AddLogSource {
+				syslog.Enrichment = syslogEnrichmentMinimal
+			}
+		}
+		obsOutput.Type = obsv1.OutputTypeSyslog
+		obsOutput.Syslog = syslog
+
+	case loggingv1.OutputTypeCloudwatch:
+		cw := &obsv1.Cloudwatch{
+			URL:            output.URL,
+			GroupName:      cloudwatchGroupName(output.Cloudwatch),
+			Authentication: cloudwatchAuthentication(secret),
+		}
+		if output.Cloudwatch != nil {
+			cw.Region = output.Cloudwatch.Region
+		}
+		obsOutput.Type = obsv1.OutputTypeCloudwatch
+		obsOutput.Cloudwatch = cw
+
+	case loggingv1.OutputTypeGoogleCloudLogging:
+		gcl := &obsv1.GoogleCloudLogging{}
+		if g := output.GoogleCloudLogging; g != nil {
+			gcl.LogID = g.LogID
+			switch {
+			case g.ProjectID != "":
+				gcl.ID = obsv1.GoogleCloudLoggingID{Type: "project", Value: g.ProjectID}
+			case g.FolderID != "":
+				gcl.ID = obsv1.GoogleCloudLoggingID{Type: "folder", Value: g.FolderID}
+			case g.OrganizationID != "":
+				gcl.ID = obsv1.GoogleCloudLoggingID{Type: "organization", Value: g.OrganizationID}
+			case g.BillingAccountID != "":
+				gcl.ID = obsv1.GoogleCloudLoggingID{Type: "billingAccount", Value: g.BillingAccountID}
+			}
+		}
+		if secret.has(secretKeyGoogleCloudCred) {
+			gcl.Authentication = &obsv1.GoogleCloudLoggingAuthentication{Credentials: secret.ref(secretKeyGoogleCloudCred)}
+		}
+		obsOutput.Type = obsv1.OutputTypeGoogleCloudLogging
+		obsOutput.GoogleCloudLogging = gcl
+
+	default:
+		return obsOutput, kverrors.New("output type is not supported by the observability API", "output", output.Name, "type", output.Type)
+	}
+
+	return obsOutput, nil
+}
+
+func convertTLS(output loggingv1.OutputSpec, secret *outputSecret) *obsv1.OutputTLSSpec {
+	tls := &obsv1.OutputTLSSpec{
+		Key:           secret.ref(secretKeyTLSKey),
+		KeyPassphrase: secret.ref(secretKeyPassphrase),
+	}
+	if secret.has(secretKeyTLSCert) {
+		tls.Certificate = &obsv1.ValueReference{Key: secretKeyTLSCert, SecretName: secret.name}
+	}
+	if secret.has(secretKeyCABundle) {
+		tls.CA = &obsv1.ValueReference{Key: secretKeyCABundle, SecretName: secret.name}
+	}
+	if output.TLS != nil {
+		tls.InsecureSkipVerify = output.TLS.InsecureSkipVerify
+		tls.TLSSecurityProfile = output.TLS.TLSSecurityProfile
+	}
+
+	if *tls == (obsv1.OutputTLSSpec{}) {
+		return nil
+	}
+	return tls
+}
+
+func httpAuthentication(secret *outputSecret) *obsv1.HTTPAuthentication {
+	auth := &obsv1.HTTPAuthentication{
+		Username: secret.ref(secretKeyUsername),
+		Password: secret.ref(secretKeyPassword),
+	}
+	if secret.has(secretKeyToken) {
+		auth.Token = &obsv1.BearerToken{
+			From:   obsv1.BearerTokenFromSecret,
+			Secret: &obsv1.BearerTokenKey{Name: secret.name, Key: secretKeyToken},
+		}
+	}
+
+	if auth.Username == nil && auth.Password == nil && auth.Token == nil {
+		return nil
+	}
+	return auth
+}
+
+func cloudwatchAuthentication(secret *outputSecret) *obsv1.CloudwatchAuthentication {
+	switch {
+	case secret.has(secretKeyAWSAccessKeyID):
+		return &obsv1.CloudwatchAuthentication{
+			Type: obsv1.CloudwatchAuthTypeAccessKey,
+			AWSAccessKey: &obsv1.CloudwatchAWSAccessKey{
+				KeyID:     *secret.ref(secretKeyAWSAccessKeyID),
+				KeySecret: obsv1.SecretReference{Key: secretKeyAWSSecretKey, SecretName: secret.name},
+			},
+		}
+	case secret.has(secretKeyAWSRoleARN):
+		return &obsv1.CloudwatchAuthentication{
+			Type: obsv1.CloudwatchAuthTypeIAMRole,
+			IAMRole: &obsv1.CloudwatchIAMRole{
+				RoleARN: *secret.ref(secretKeyAWSRoleARN),
+				Token:   obsv1.BearerToken{From: obsv1.BearerTokenFromServiceAccount},
+			},
+		}
+	}
+	return nil
+}
+
+// cloudwatchGroupName translates the groupBy and groupPrefix fields of the v1
+// API to the group name template of the observability API.
+func cloudwatchGroupName(cw *loggingv1.Cloudwatch) string {
+	field := "log_type"
+	if cw == nil {
+		return fieldTemplate(field)
+	}
+
+	switch cw.GroupBy {
+	case loggingv1.LogGroupByNamespaceName:
+		field = "kubernetes.namespace_name"
+	case loggingv1.LogGroupByNamespaceUUID:
+		field = "kubernetes.namespace_id"
+	}
+
+	groupName := fieldTemplate(field)
+	if cw.GroupPrefix != nil && *cw.GroupPrefix != "" {
+		groupName = fmt.Sprintf("%s.%s", *cw.GroupPrefix, groupName)
+	}
+	return groupName
+}
+
+// fieldTemplate turns a record field path into a template of the observability
+// API. Values that are already templates are returned as they are.
+func fieldTemplate(field string) string {
+	if field == "" || strings.HasPrefix(field, "{") {
+		return field
+	}
+	return fmt.Sprintf(`{.%s||"none"}`, strings.TrimPrefix(field, "."))
+}
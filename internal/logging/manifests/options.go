@@ -4,6 +4,7 @@ import (
 	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
 	corev1 "k8s.io/api/core/v1"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 )
 
 type Options struct {
@@ -11,4 +12,14 @@ type Options struct {
 	ConfigMaps            []corev1.ConfigMap
 	ClusterLogForwarder   *loggingv1.ClusterLogForwarder
 	AddOnDeploymentConfig *addonapiv1alpha1.AddOnDeploymentConfig
+	ManagedCluster        *clusterv1.ManagedCluster
+	// ClusterLogForwarderOverlay is an optional ClusterLogForwarder in the
+	// namespace of the managed cluster that is merged onto the default one
+	ClusterLogForwarderOverlay *loggingv1.ClusterLogForwarder
+	// LokiStackGatewayURL is the base URL of the tenants API of a LokiStack
+	// gateway on the hub used for Loki outputs without a URL
+	LokiStackGatewayURL string
+	// HubRunsOperator is set when the managed cluster is the hub and the
+	// logging operator is already installed there
+	HubRunsOperator bool
 }
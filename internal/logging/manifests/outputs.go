@@ -0,0 +1,344 @@
+package manifests
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Keys that can be set in a ConfigMap annotated with
+// AnnotationTargetOutputName to template the matching output.
+const (
+	configMapKeyURL              = "url"
+	configMapKeyTenantKey        = "tenantKey"
+	configMapKeyVersion          = "version"
+	configMapKeyTopic            = "topic"
+	configMapKeyBrokers          = "brokers"
+	configMapKeyMethod           = "method"
+	configMapKeyRFC              = "rfc"
+	configMapKeyFacility         = "facility"
+	configMapKeySeverity         = "severity"
+	configMapKeyAppName          = "appName"
+	configMapKeyRegion           = "region"
+	configMapKeyGroupBy          = "groupBy"
+	configMapKeyGroupPrefix      = "groupPrefix"
+	configMapKeyProjectID        = "projectId"
+	configMapKeyFolderID         = "folderId"
+	configMapKeyOrganizationID   = "organizationId"
+	configMapKeyBillingAccountID = "billingAccountId"
+	configMapKeyLogID            = "logId"
+)
+
+// outputFieldFunc sets the value of a ConfigMap key on an output.
+type outputFieldFunc func(output *loggingv1.OutputSpec, value string) error
+
+// outputConfigMapFields maps, for each output type, the ConfigMap keys that are
+// supported to the output field they set.
+var outputConfigMapFields = map[string]map[string]outputFieldFunc{
+	loggingv1.OutputTypeLoki: {
+		configMapKeyURL:       setURL,
+		configMapKeyTenantKey: setLokiTenantKey,
+	},
+	loggingv1.OutputTypeElasticsearch: {
+		configMapKeyURL:     setURL,
+		configMapKeyVersion: setElasticsearchVersion,
+	},
+	loggingv1.OutputTypeKafka: {
+		configMapKeyURL:     setURL,
+		configMapKeyTopic:   setKafkaTopic,
+		configMapKeyBrokers: setKafkaBrokers,
+	},
+	loggingv1.OutputTypeSplunk: {
+		configMapKeyURL: setURL,
+	},
+	loggingv1.OutputTypeHttp: {
+		configMapKeyURL:    setURL,
+		configMapKeyMethod: setHTTPMethod,
+	},
+	loggingv1.OutputTypeSyslog: {
+		configMapKeyURL:      setURL,
+		configMapKeyRFC:      setSyslogRFC,
+		configMapKeyFacility: setSyslogFacility,
+		configMapKeySeverity: setSyslogSeverity,
+		configMapKeyAppName:  setSyslogAppName,
+	},
+	loggingv1.OutputTypeCloudwatch: {
+		configMapKeyURL:         setURL,
+		configMapKeyRegion:      setCloudwatchRegion,
+		configMapKeyGroupBy:     setCloudwatchGroupBy,
+		configMapKeyGroupPrefix: setCloudwatchGroupPrefix,
+	},
+	loggingv1.OutputTypeGoogleCloudLogging: {
+		configMapKeyProjectID:        setGoogleCloudLoggingProjectID,
+		configMapKeyFolderID:         setGoogleCloudLoggingFolderID,
+		configMapKeyOrganizationID:   setGoogleCloudLoggingOrganizationID,
+		configMapKeyBillingAccountID: setGoogleCloudLoggingBillingAccountID,
+		configMapKeyLogID:            setGoogleCloudLoggingLogID,
+	},
+	loggingv1.OutputTypeFluentdForward: {
+		configMapKeyURL: setURL,
+	},
+}
+
+// secretKeyPairs lists the secret keys that are only meaningful together, e.g.
+// a client certificate without its private key.
+var secretKeyPairs = [][2]string{
+	{secretKeyTLSCert, secretKeyTLSKey},
+	{secretKeyUsername, secretKeyPassword},
+	{secretKeyAWSAccessKeyID, secretKeyAWSSecretKey},
+}
+
+// outputSecretKeys maps, for each output type, the sets of secret keys that
+// configure one of the authentication methods it supports. A secret referenced
+// by an output must contain all the keys of at least one of these sets.
+var outputSecretKeys = map[string][][]string{
+	loggingv1.OutputTypeLoki: {
+		{secretKeyTLSCert, secretKeyTLSKey},
+		{secretKeyUsername, secretKeyPassword},
+		{secretKeyToken},
+		{secretKeyCABundle},
+	},
+	loggingv1.OutputTypeElasticsearch: {
+		{secretKeyTLSCert, secretKeyTLSKey},
+		{secretKeyUsername, secretKeyPassword},
+		{secretKeyToken},
+		{secretKeyCABundle},
+	},
+	loggingv1.OutputTypeHttp: {
+		{secretKeyTLSCert, secretKeyTLSKey},
+		{secretKeyUsername, secretKeyPassword},
+		{secretKeyToken},
+		{secretKeyCABundle},
+	},
+	loggingv1.OutputTypeKafka: {
+		{secretKeyTLSCert, secretKeyTLSKey},
+		{secretKeyUsername, secretKeyPassword},
+		{secretKeyCABundle},
+	},
+	loggingv1.OutputTypeSyslog: {
+		{secretKeyTLSCert, secretKeyTLSKey},
+		{secretKeyCABundle},
+	},
+	loggingv1.OutputTypeSplunk: {
+		{secretKeySplunkHECToken},
+	},
+	loggingv1.OutputTypeCloudwatch: {
+		{secretKeyAWSAccessKeyID, secretKeyAWSSecretKey},
+		{secretKeyAWSRoleARN},
+		{secretKeyAWSCredentials},
+	},
+	loggingv1.OutputTypeGoogleCloudLogging: {
+		{secretKeyGoogleCloudCred},
+	},
+	loggingv1.OutputTypeFluentdForward: {
+		{secretKeyTLSCert, secretKeyTLSKey},
+		{secretKeySharedKey},
+		{secretKeyCABundle},
+	},
+}
+
+// validateOutputSecret checks that secret contains the keys required by at
+// least one of the authentication methods supported by the output type.
+func validateOutputSecret(output loggingv1.OutputSpec, secret corev1.Secret) error {
+	keySets, ok := outputSecretKeys[output.Type]
+	if !ok {
+		return kverrors.New("output type does not support secrets", "output", output.Name, "type", output.Type, "secret", secret.Name)
+	}
+
+	for _, pair := range secretKeyPairs {
+		_, hasFirst := secret.Data[pair[0]]
+		_, hasSecond := secret.Data[pair[1]]
+		switch {
+		case hasFirst && !hasSecond:
+			return kverrors.New("secret is missing required key", "key", pair[1], "requiredBy", pair[0], "secret", secret.Name, "output", output.Name)
+		case !hasFirst && hasSecond:
+			return kverrors.New("secret is missing required key", "key", pair[0], "requiredBy", pair[1], "secret", secret.Name, "output", output.Name)
+		}
+	}
+
+	for _, keys := range keySets {
+		if hasSecretKeys(secret, keys) {
+			return nil
+		}
+	}
+
+	expected := make([]string, 0, len(keySets))
+	for _, keys := range keySets {
+		expected = append(expected, strings.Join(keys, "+"))
+	}
+	return kverrors.New("secret does not contain the keys required by output type",
+		"secret", secret.Name,
+		"output", output.Name,
+		"type", output.Type,
+		"expected", strings.Join(expected, " or "))
+}
+
+func hasSecretKeys(secret corev1.Secret, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := secret.Data[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func setURL(output *loggingv1.OutputSpec, value string) error {
+	output.URL = value
+	return nil
+}
+
+func setLokiTenantKey(output *loggingv1.OutputSpec, value string) error {
+	if output.Loki == nil {
+		output.Loki = &loggingv1.Loki{}
+	}
+	output.Loki.TenantKey = value
+	return nil
+}
+
+func setElasticsearchVersion(output *loggingv1.OutputSpec, value string) error {
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return kverrors.Wrap(err, "invalid elasticsearch version", "value", value)
+	}
+	if output.Elasticsearch == nil {
+		output.Elasticsearch = &loggingv1.Elasticsearch{}
+	}
+	output.Elasticsearch.Version = version
+	return nil
+}
+
+func setKafkaTopic(output *loggingv1.OutputSpec, value string) error {
+	if output.Kafka == nil {
+		output.Kafka = &loggingv1.Kafka{}
+	}
+	output.Kafka.Topic = value
+	return nil
+}
+
+// setKafkaBrokers expects a comma separated list of broker URLs.
+func setKafkaBrokers(output *loggingv1.OutputSpec, value string) error {
+	if output.Kafka == nil {
+		output.Kafka = &loggingv1.Kafka{}
+	}
+	brokers := []string{}
+	for _, broker := range strings.Split(value, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	output.Kafka.Brokers = brokers
+	return nil
+}
+
+func setHTTPMethod(output *loggingv1.OutputSpec, value string) error {
+	if output.Http == nil {
+		output.Http = &loggingv1.Http{}
+	}
+	output.Http.Method = value
+	return nil
+}
+
+func setSyslogRFC(output *loggingv1.OutputSpec, value string) error {
+	if output.Syslog == nil {
+		output.Syslog = &loggingv1.Syslog{}
+	}
+	output.Syslog.RFC = value
+	return nil
+}
+
+func setSyslogFacility(output *loggingv1.OutputSpec, value string) error {
+	if output.Syslog == nil {
+		output.Syslog = &loggingv1.Syslog{}
+	}
+	output.Syslog.Facility = value
+	return nil
+}
+
+func setSyslogSeverity(output *loggingv1.OutputSpec, value string) error {
+	if output.Syslog == nil {
+		output.Syslog = &loggingv1.Syslog{}
+	}
+	output.Syslog.Severity = value
+	return nil
+}
+
+func setSyslogAppName(output *loggingv1.OutputSpec, value string) error {
+	if output.Syslog == nil {
+		output.Syslog = &loggingv1.Syslog{}
+	}
+	output.Syslog.AppName = value
+	return nil
+}
+
+func setCloudwatchRegion(output *loggingv1.OutputSpec, value string) error {
+	if output.Cloudwatch == nil {
+		output.Cloudwatch = &loggingv1.Cloudwatch{}
+	}
+	output.Cloudwatch.Region = value
+	return nil
+}
+
+func setCloudwatchGroupBy(output *loggingv1.OutputSpec, value string) error {
+	groupBy := loggingv1.LogGroupByType(value)
+	switch groupBy {
+	case loggingv1.LogGroupByLogType, loggingv1.LogGroupByNamespaceName, loggingv1.LogGroupByNamespaceUUID:
+	default:
+		return kverrors.New("invalid cloudwatch groupBy", "value", value)
+	}
+	if output.Cloudwatch == nil {
+		output.Cloudwatch = &loggingv1.Cloudwatch{}
+	}
+	output.Cloudwatch.GroupBy = groupBy
+	return nil
+}
+
+func setCloudwatchGroupPrefix(output *loggingv1.OutputSpec, value string) error {
+	if output.Cloudwatch == nil {
+		output.Cloudwatch = &loggingv1.Cloudwatch{}
+	}
+	output.Cloudwatch.GroupPrefix = &value
+	return nil
+}
+
+func setGoogleCloudLoggingProjectID(output *loggingv1.OutputSpec, value string) error {
+	if output.GoogleCloudLogging == nil {
+		output.GoogleCloudLogging = &loggingv1.GoogleCloudLogging{}
+	}
+	output.GoogleCloudLogging.ProjectID = value
+	return nil
+}
+
+func setGoogleCloudLoggingFolderID(output *loggingv1.OutputSpec, value string) error {
+	if output.GoogleCloudLogging == nil {
+		output.GoogleCloudLogging = &loggingv1.GoogleCloudLogging{}
+	}
+	output.GoogleCloudLogging.FolderID = value
+	return nil
+}
+
+func setGoogleCloudLoggingOrganizationID(output *loggingv1.OutputSpec, value string) error {
+	if output.GoogleCloudLogging == nil {
+		output.GoogleCloudLogging = &loggingv1.GoogleCloudLogging{}
+	}
+	output.GoogleCloudLogging.OrganizationID = value
+	return nil
+}
+
+func setGoogleCloudLoggingBillingAccountID(output *loggingv1.OutputSpec, value string) error {
+	if output.GoogleCloudLogging == nil {
+		output.GoogleCloudLogging = &loggingv1.GoogleCloudLogging{}
+	}
+	output.GoogleCloudLogging.BillingAccountID = value
+	return nil
+}
+
+func setGoogleCloudLoggingLogID(output *loggingv1.OutputSpec, value string) error {
+	if output.GoogleCloudLogging == nil {
+		output.GoogleCloudLogging = &loggingv1.GoogleCloudLogging{}
+	}
+	output.GoogleCloudLogging.LogID = value
+	return nil
+}
@@ -0,0 +1,61 @@
+package manifests
+
+import (
+	loggingv1 "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+)
+
+// mergeClusterLogForwarderSpec merges the spec of a per-cluster overlay
+// ClusterLogForwarder onto the default spec. Inputs, outputs, filters and
+// pipelines are keyed on their name: an overlay entry replaces the default
+// entry with the same name and entries with new names are appended after the
+// default ones. Unnamed pipelines of the overlay are always appended. The
+// output defaults and service account of the overlay replace the default ones
+// when set.
+func mergeClusterLogForwarderSpec(base, overlay loggingv1.ClusterLogForwarderSpec) loggingv1.ClusterLogForwarderSpec {
+	merged := *base.DeepCopy()
+	overlay = *overlay.DeepCopy()
+
+	if overlay.ServiceAccountName != "" {
+		merged.ServiceAccountName = overlay.ServiceAccountName
+	}
+	if overlay.OutputDefaults != nil {
+		merged.OutputDefaults = overlay.OutputDefaults
+	}
+
+	merged.Inputs = mergeByName(merged.Inputs, overlay.Inputs, func(i loggingv1.InputSpec) string { return i.Name })
+	merged.Outputs = mergeByName(merged.Outputs, overlay.Outputs, func(o loggingv1.OutputSpec) string { return o.Name })
+	merged.Filters = mergeByName(merged.Filters, overlay.Filters, func(f loggingv1.FilterSpec) string { return f.Name })
+	merged.Pipelines = mergeByName(merged.Pipelines, overlay.Pipelines, func(p loggingv1.PipelineSpec) string { return p.Name })
+
+	return merged
+}
+
+// mergeByName returns the items of base where the items of overlay with the
+// same name replace them, followed by the remaining items of overlay. A name
+// repeated in overlay keeps its last item. Items without a name are never
+// matched. base isn't modified.
+func mergeByName[T any](base, overlay []T, name func(T) string) []T {
+	merged := make([]T, len(base), len(base)+len(overlay))
+	copy(merged, base)
+
+	index := make(map[string]int, len(merged))
+	for i, item := range merged {
+		if n := name(item); n != "" {
+			index[n] = i
+		}
+	}
+
+	for _, item := range overlay {
+		n := name(item)
+		if i, ok := index[n]; ok {
+			merged[i] = item
+			continue
+		}
+		if n != "" {
+			index[n] = len(merged)
+		}
+		merged = append(merged, item)
+	}
+
+	return merged
+}
@@ -2,12 +2,19 @@ package manifests
 
 import (
 	"encoding/json"
+
+	obsv1 "github.com/rhobs/multicluster-observability-addon/internal/logging/apis/observability/v1"
 )
 
 type LoggingValues struct {
 	Enabled                    bool          `json:"enabled"`
+	Namespace                  string        `json:"namespace"`
+	CLFAPIVersion              string        `json:"clfAPIVersion"`
 	CLFSpec                    string        `json:"clfSpec"`
+	CollectorSpec              string        `json:"collectorSpec"`
+	ServiceAccountName         string        `json:"serviceAccountName"`
 	LoggingSubscriptionChannel string        `json:"loggingSubscriptionChannel"`
+	InstallOperator            bool          `json:"installOperator"`
 	Secrets                    []SecretValue `json:"secrets"`
 }
 type SecretValue struct {
@@ -17,10 +24,21 @@ type SecretValue struct {
 
 func BuildValues(opts Options) (*LoggingValues, error) {
 	values := &LoggingValues{
-		Enabled: true,
+		Enabled:         true,
+		InstallOperator: !opts.HubRunsOperator,
 	}
 
+	namespace, err := BuildNamespace(opts)
+	if err != nil {
+		return nil, err
+	}
+	values.Namespace = namespace
 	values.LoggingSubscriptionChannel = buildSubscriptionChannel(opts)
+	clfAPIVersion, err := buildCLFAPIVersion(opts)
+	if err != nil {
+		return nil, err
+	}
+	values.CLFAPIVersion = clfAPIVersion
 
 	secrets, err := buildSecrets(opts)
 	if err != nil {
@@ -33,7 +51,46 @@ func BuildValues(opts Options) (*LoggingValues, error) {
 		return nil, err
 	}
 
-	b, err := json.Marshal(clfSpec)
+	collector, err := buildCollectorSpec(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec interface{} = clfSpec
+	if values.CLFAPIVersion == obsv1.GroupVersion.String() {
+		obsSpec, err := buildObservabilityCLFSpec(clfSpec, opts.Secrets, collectorServiceAccountName)
+		if err != nil {
+			return nil, err
+		}
+		// The collector is configured in the ClusterLogForwarder with the
+		// observability API
+		if collector != nil {
+			obsSpec.Collector = &obsv1.CollectorSpec{
+				Resources:    collector.Resources,
+				NodeSelector: collector.NodeSelector,
+				Tolerations:  collector.Tolerations,
+			}
+		}
+		spec = obsSpec
+		values.ServiceAccountName = collectorServiceAccountName
+	} else {
+		// Outside of openshift-logging the collector of a
+		// logging.openshift.io/v1 ClusterLogForwarder runs with a service
+		// account bound to the roles for collecting logs
+		if values.Namespace != defaultNamespace {
+			clfSpec.ServiceAccountName = collectorServiceAccountName
+			values.ServiceAccountName = collectorServiceAccountName
+		}
+		if collector != nil {
+			b, err := json.Marshal(collector)
+			if err != nil {
+				return nil, err
+			}
+			values.CollectorSpec = string(b)
+		}
+	}
+
+	b, err := json.Marshal(spec)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,8 @@
 package manifests
 
 import (
+	"fmt"
+
 	v1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/rhobs/multicluster-observability-addon/internal/addon/authentication"
 	"github.com/rhobs/multicluster-observability-addon/internal/manifests"
@@ -14,29 +16,97 @@ const (
 	subscriptionChannelValueKey = "loggingSubscriptionChannel"
 	defaultLoggingVersion       = "stable-5.8"
 
+	// namespaceValueKey sets the namespace where the logging operator and the
+	// collector are installed on the spoke cluster
+	namespaceValueKey = "loggingNamespace"
+	defaultNamespace  = "openshift-logging"
+
+	// clusterLabelsValueKey selects, as a comma separated list, the labels of
+	// the ManagedCluster that are added as stream labels to Loki outputs
+	clusterLabelsValueKey = "loggingClusterLabels"
+	// lokiClusterTenantValueKey enables the use of the cluster name as tenant
+	// for Loki outputs that don't set a tenant key
+	lokiClusterTenantValueKey = "loggingLokiClusterTenant"
+
+	// Resources of the collector pods, expressed as Kubernetes quantities
+	collectorCPURequestValueKey    = "loggingCollectorCPURequest"
+	collectorMemoryRequestValueKey = "loggingCollectorMemoryRequest"
+	collectorCPULimitValueKey      = "loggingCollectorCPULimit"
+	collectorMemoryLimitValueKey   = "loggingCollectorMemoryLimit"
+
+	lokiStackTenantApplication    = "application"
+	lokiStackTenantInfrastructure = "infrastructure"
+	lokiStackTenantAudit          = "audit"
+
+	openshiftLabelsPrefix = "openshift.labels."
+	lokiLabelClusterName  = "cluster_name"
+	lokiLabelClusterID    = "cluster_id"
+
+	// clusterClaimLoggingVersion is the name of the ClusterClaim that reports
+	// the version of the logging operator installed on the spoke cluster
+	clusterClaimLoggingVersion = "version.logging.openshift.io"
+	// observabilityAPIMajorVersion is the first major version of the logging
+	// operator that only supports the observability.openshift.io/v1 API
+	observabilityAPIMajorVersion = 6
+
+	collectorServiceAccountName = "mcoa-logcollector"
+	defaultElasticsearchVersion = 8
+	defaultSyslogRFC            = "RFC5424"
+	syslogEnrichmentMinimal     = "KubernetesMinimal"
+
 	certOrganizatonalUnit = "multicluster-observability-addon"
-	certDNSNameCollector  = "collector.openshift-logging.svc"
+	// certDNSNameCollector is formatted with the name and the namespace of
+	// the collector service
+	certDNSNameCollector = "%s.%s.svc"
+	// clusterLogForwarderName is the name of the rendered ClusterLogForwarder
+	clusterLogForwarderName = "instance"
+	// legacyCollectorName is the name of the collector of the
+	// ClusterLogForwarder openshift-logging/instance of logging.openshift.io/v1
+	legacyCollectorName = "collector"
 
 	staticSecretName      = "static-authentication"
 	staticSecretNamespace = "open-cluster-management"
 )
 
-var AuthDefaultConfig = &authentication.Config{
-	StaticAuthConfig: manifests.StaticAuthenticationConfig{
-		ExistingSecret: client.ObjectKey{
-			Name:      staticSecretName,
-			Namespace: staticSecretNamespace,
-		},
-	},
-	MTLSConfig: manifests.MTLSConfig{
-		CommonName: "", // Should be set when using these defaults
-		Subject: &v1.X509Subject{
-			OrganizationalUnits: []string{
-				certOrganizatonalUnit,
+// defaultLokiLabelKeys are the label keys used by the collector when a Loki
+// output doesn't set any. They are kept when cluster labels are added.
+var defaultLokiLabelKeys = []string{
+	"log_type",
+	"kubernetes.namespace_name",
+	"kubernetes.pod_name",
+	"kubernetes.container_name",
+}
+
+// BuildAuthConfig returns the configuration used to generate the secrets of
+// the outputs. The certificates are issued for commonName and for the
+// collector service on the spoke cluster.
+func BuildAuthConfig(commonName string, resources Options) (*authentication.Config, error) {
+	namespace, err := BuildNamespace(resources)
+	if err != nil {
+		return nil, err
+	}
+	clfAPIVersion, err := buildCLFAPIVersion(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authentication.Config{
+		StaticAuthConfig: manifests.StaticAuthenticationConfig{
+			ExistingSecret: client.ObjectKey{
+				Name:      staticSecretName,
+				Namespace: staticSecretNamespace,
 			},
 		},
-		DNSNames: []string{
-			certDNSNameCollector,
+		MTLSConfig: manifests.MTLSConfig{
+			CommonName: commonName,
+			Subject: &v1.X509Subject{
+				OrganizationalUnits: []string{
+					certOrganizatonalUnit,
+				},
+			},
+			DNSNames: []string{
+				fmt.Sprintf(certDNSNameCollector, collectorServiceName(namespace, clfAPIVersion), namespace),
+			},
 		},
-	},
+	}, nil
 }
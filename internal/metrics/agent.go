@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// agentReplicasKey holds in a metrics ConfigMap the number of replicas
+	// of the metrics agent, more than one requires a PrometheusAgent
+	agentReplicasKey = "agentReplicas"
+	// agentLogLevelKey holds in a metrics ConfigMap the log level of the
+	// metrics agent
+	agentLogLevelKey = "agentLogLevel"
+	// agentPriorityClassNameKey holds in a metrics ConfigMap the priority
+	// class of the pods of the metrics agent
+	agentPriorityClassNameKey = "agentPriorityClassName"
+	// agentResourcesKey holds in a metrics ConfigMap the compute resources of
+	// the metrics agent container in YAML. Each request and limit replaces
+	// the default one with the same resource name.
+	agentResourcesKey = "agentResources"
+
+	defaultAgentReplicas = 1
+	defaultAgentLogLevel = "info"
+)
+
+var agentLogLevels = map[string]bool{
+	"debug": true,
+	"info":  true,
+	"warn":  true,
+	"error": true,
+}
+
+type AgentValues struct {
+	Replicas          int32                       `json:"replicas"`
+	LogLevel          string                      `json:"logLevel"`
+	PriorityClassName string                      `json:"priorityClassName"`
+	Resources         corev1.ResourceRequirements `json:"resources"`
+}
+
+func defaultAgentResources() corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("500m"),
+			corev1.ResourceMemory: resource.MustParse("500M"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+}
+
+// getAgentValues returns the deployment settings of the metrics agent from
+// the defaults and the metrics configuration data. The per-cluster overlay
+// of a metrics ConfigMap allows to size the agent of a single cluster.
+func getAgentValues(configs []configData) (AgentValues, error) {
+	values := AgentValues{
+		Replicas:  defaultAgentReplicas,
+		LogLevel:  defaultAgentLogLevel,
+		Resources: defaultAgentResources(),
+	}
+
+	for _, config := range configs {
+		if raw, ok := config.data[agentReplicasKey]; ok {
+			replicas, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 32)
+			if err != nil {
+				return values, kverrors.Wrap(err, "invalid agent replicas", "name", config.key.Name, "namespace", config.key.Namespace)
+			}
+			if replicas < 1 {
+				return values, kverrors.New("agent replicas must be positive", "name", config.key.Name, "namespace", config.key.Namespace)
+			}
+			values.Replicas = int32(replicas)
+		}
+
+		if raw, ok := config.data[agentLogLevelKey]; ok {
+			level := strings.TrimSpace(raw)
+			if !agentLogLevels[level] {
+				return values, kverrors.New("invalid agent log level", "level", level, "name", config.key.Name, "namespace", config.key.Namespace)
+			}
+			values.LogLevel = level
+		}
+
+		if raw, ok := config.data[agentPriorityClassNameKey]; ok {
+			name := strings.TrimSpace(raw)
+			if errs := validation.IsDNS1123Subdomain(name); name != "" && len(errs) > 0 {
+				return values, kverrors.New("invalid agent priority class name", "priorityClassName", name, "reason", strings.Join(errs, ", "))
+			}
+			values.PriorityClassName = name
+		}
+
+		if raw, ok := config.data[agentResourcesKey]; ok {
+			resources := corev1.ResourceRequirements{}
+			if err := yaml.Unmarshal([]byte(raw), &resources); err != nil {
+				return values, kverrors.Wrap(err, "failed to parse agent resources", "name", config.key.Name, "namespace", config.key.Namespace)
+			}
+			values.Resources.Requests = mergeResourceList(values.Resources.Requests, resources.Requests)
+			values.Resources.Limits = mergeResourceList(values.Resources.Limits, resources.Limits)
+		}
+	}
+
+	if err := validateAgentResources(values.Resources); err != nil {
+		return values, err
+	}
+	return values, nil
+}
+
+func mergeResourceList(base, overlay corev1.ResourceList) corev1.ResourceList {
+	list := make(corev1.ResourceList, len(base)+len(overlay))
+	for name, quantity := range base {
+		list[name] = quantity
+	}
+	for name, quantity := range overlay {
+		list[name] = quantity
+	}
+	return list
+}
+
+func validateAgentResources(resources corev1.ResourceRequirements) error {
+	for name, request := range resources.Requests {
+		if request.Sign() < 0 {
+			return kverrors.New("agent resource request must not be negative", "resource", name)
+		}
+		limit, ok := resources.Limits[name]
+		if ok && request.Cmp(limit) > 0 {
+			return kverrors.New("agent resource request must not exceed its limit", "resource", name, "request", request.String(), "limit", limit.String())
+		}
+	}
+	for name, limit := range resources.Limits {
+		if limit.Sign() < 0 {
+			return kverrors.New("agent resource limit must not be negative", "resource", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	"github.com/rhobs/multicluster-observability-addon/internal/addon/authentication"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// alertmanagerRefValueKey references, as kind/namespace/name, the Route,
+	// Ingress or LoadBalancer Service exposing the hub Alertmanager. Setting
+	// it enables the forwarding of the alerts of the managed clusters.
+	alertmanagerRefValueKey = "metricsAlertmanagerRef"
+	// alertmanagerEndpointValueKey sets the URL of the hub Alertmanager,
+	// skipping the discovery
+	alertmanagerEndpointValueKey = "metricsAlertmanagerEndpoint"
+	// clusterMonitoringConfigKey holds in a metrics ConfigMap the complete
+	// configuration of the cluster monitoring of the managed cluster, which the
+	// alert forwarding is added to. Setting it hands over the
+	// cluster-monitoring-config ConfigMap of the managed cluster to the addon,
+	// which is required to forward alerts. The ConfigMap is left in place
+	// when the addon is removed.
+	clusterMonitoringConfigKey = "clusterMonitoringConfig"
+
+	// alertmanagerTarget names the hub Alertmanager in the authentication
+	// key of a metrics ConfigMap
+	alertmanagerTarget = "alertmanager"
+	// alertmanagerSecretName is the secret holding the credentials of the
+	// hub Alertmanager in the namespace of the cluster monitoring
+	alertmanagerSecretName = "metrics-alertmanager-auth"
+)
+
+type AlertForwardingValues struct {
+	Enabled bool `json:"enabled"`
+	// ManageConfig is set when the cluster monitoring configuration is handed
+	// over to the addon, whether alerts are forwarded or not
+	ManageConfig            bool                   `json:"manageConfig"`
+	ClusterMonitoringConfig map[string]interface{} `json:"clusterMonitoringConfig"`
+	Secrets                 []SecretValue          `json:"secrets"`
+}
+
+// alertmanagerConfig maps to an additionalAlertmanagerConfigs entry of the
+// cluster monitoring configuration.
+type alertmanagerConfig struct {
+	APIVersion    string                    `json:"apiVersion"`
+	Scheme        string                    `json:"scheme"`
+	PathPrefix    string                    `json:"pathPrefix,omitempty"`
+	StaticConfigs []string                  `json:"staticConfigs"`
+	TLSConfig     *alertmanagerTLSConfig    `json:"tlsConfig,omitempty"`
+	BearerToken   *corev1.SecretKeySelector `json:"bearerToken,omitempty"`
+}
+
+type alertmanagerTLSConfig struct {
+	CA   *corev1.SecretKeySelector `json:"ca,omitempty"`
+	Cert *corev1.SecretKeySelector `json:"cert,omitempty"`
+	Key  *corev1.SecretKeySelector `json:"key,omitempty"`
+}
+
+// getAlertForwardingValues returns the cluster monitoring configuration
+// sending the alerts of the managed cluster to the hub Alertmanager with the
+// labels identifying the cluster. The addon can't merge its settings into the
+// configuration living on the managed cluster, so forwarding alerts requires
+// the complete configuration to be provided in a metrics ConfigMap. The
+// credentials of the Alertmanager are generated with the authentication type
+// set for the alertmanager target.
+func getAlertForwardingValues(k8sClient client.Client, cluster *clusterv1.ManagedCluster, clusterName string, adoc *addonapiv1alpha1.AddOnDeploymentConfig, configs []configData, externalLabels map[string]string) (AlertForwardingValues, error) {
+	values := AlertForwardingValues{
+		ClusterMonitoringConfig: map[string]interface{}{},
+		Secrets:                 []SecretValue{},
+	}
+
+	config, ok, err := getClusterMonitoringConfig(configs)
+	if err != nil {
+		return values, err
+	}
+	values.ManageConfig = ok
+	values.ClusterMonitoringConfig = config
+
+	endpoint, err := getAlertmanagerEndpoint(k8sClient, cluster, adoc)
+	if err != nil || endpoint == "" {
+		return values, err
+	}
+	if !values.ManageConfig {
+		return values, kverrors.New("forwarding alerts requires the cluster monitoring configuration of the managed cluster in a metrics ConfigMap", "key", clusterMonitoringConfigKey)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return values, kverrors.New("invalid hub Alertmanager endpoint", "endpoint", endpoint)
+	}
+	amConfig := alertmanagerConfig{
+		APIVersion:    "v2",
+		Scheme:        u.Scheme,
+		PathPrefix:    strings.TrimSuffix(u.Path, "/"),
+		StaticConfigs: []string{u.Host},
+	}
+
+	authTypes, ca, err := getAuthentication(configs)
+	if err != nil {
+		return values, err
+	}
+	if authType, ok := authTypes[alertmanagerTarget]; ok {
+		targetAuthType := map[authentication.Target]authentication.AuthenticationType{alertmanagerTarget: authType}
+		secrets, err := fetchAuthenticationSecrets(k8sClient, clusterName, ca, targetAuthType)
+		if err != nil {
+			return values, err
+		}
+		secret := secrets[alertmanagerTarget]
+		secretValue, err := withAlertmanagerSecret(&amConfig, &secret)
+		if err != nil {
+			return values, kverrors.Wrap(err, "invalid hub Alertmanager secret", "name", secret.Name)
+		}
+		values.Secrets = append(values.Secrets, *secretValue)
+	}
+
+	if err := withAlertForwarding(config, amConfig, externalLabels); err != nil {
+		return values, err
+	}
+
+	values.Enabled = true
+	return values, nil
+}
+
+// getClusterMonitoringConfig returns the cluster monitoring configuration of
+// the managed cluster and whether it is set in the metrics configuration data.
+func getClusterMonitoringConfig(configs []configData) (map[string]interface{}, bool, error) {
+	config := map[string]interface{}{}
+	found := false
+	for _, c := range configs {
+		raw, ok := c.data[clusterMonitoringConfigKey]
+		if !ok {
+			continue
+		}
+		config = map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(raw), &config); err != nil {
+			return nil, false, kverrors.Wrap(err, "failed to parse cluster monitoring configuration", "name", c.key.Name, "namespace", c.key.Namespace)
+		}
+		if config == nil {
+			config = map[string]interface{}{}
+		}
+		found = true
+	}
+	return config, found, nil
+}
+
+func getAlertmanagerEndpoint(k8sClient client.Client, cluster *clusterv1.ManagedCluster, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (string, error) {
+	if adoc == nil {
+		return "", nil
+	}
+
+	ref := ""
+	for _, customVar := range adoc.Spec.CustomizedVariables {
+		switch customVar.Name {
+		case alertmanagerEndpointValueKey:
+			if customVar.Value != "" {
+				return customVar.Value, nil
+			}
+		case alertmanagerRefValueKey:
+			ref = customVar.Value
+		}
+	}
+	if ref == "" {
+		return "", nil
+	}
+
+	u, err := addon.DiscoverURL(k8sClient, alertmanagerRefValueKey, ref, addon.IsHubCluster(cluster))
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// withAlertmanagerSecret configures the TLS and bearer token of the hub
+// Alertmanager from the keys of the secret and returns the secret to create
+// in the namespace of the cluster monitoring.
+func withAlertmanagerSecret(amConfig *alertmanagerConfig, secret *corev1.Secret) (*SecretValue, error) {
+	ref := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: alertmanagerSecretName},
+			Key:                  key,
+		}
+	}
+
+	if _, ok := secret.Data[secretKeyUsername]; ok {
+		return nil, kverrors.New("the cluster monitoring doesn't support basic authentication with Alertmanager")
+	}
+	_, hasCert := secret.Data[secretKeyCert]
+	_, hasKey := secret.Data[secretKeyKey]
+	if hasCert != hasKey {
+		return nil, kverrors.New("Alertmanager secret must set both tls.crt and tls.key")
+	}
+
+	tlsConfig := &alertmanagerTLSConfig{}
+	for _, k := range []string{secretKeyCA, secretKeyIssuerCA} {
+		if _, ok := secret.Data[k]; ok {
+			tlsConfig.CA = ref(k)
+			break
+		}
+	}
+	if hasCert {
+		tlsConfig.Cert = ref(secretKeyCert)
+		tlsConfig.Key = ref(secretKeyKey)
+	}
+	if tlsConfig.CA != nil || tlsConfig.Cert != nil {
+		amConfig.TLSConfig = tlsConfig
+	}
+	if _, ok := secret.Data[secretKeyToken]; ok {
+		amConfig.BearerToken = ref(secretKeyToken)
+	}
+
+	b, err := json.Marshal(secret.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretValue{Name: alertmanagerSecretName, Data: string(b)}, nil
+}
+
+// withAlertForwarding adds the hub Alertmanager and the labels identifying
+// the cluster to the platform Prometheus of the cluster monitoring
+// configuration. Labels identifying the cluster replace the configured ones.
+func withAlertForwarding(config map[string]interface{}, amConfig alertmanagerConfig, externalLabels map[string]string) error {
+	prometheusK8s, ok := config["prometheusK8s"].(map[string]interface{})
+	if !ok {
+		if config["prometheusK8s"] != nil {
+			return kverrors.New("invalid cluster monitoring configuration, prometheusK8s must be a map")
+		}
+		prometheusK8s = map[string]interface{}{}
+	}
+
+	amConfigs, ok := prometheusK8s["additionalAlertmanagerConfigs"].([]interface{})
+	if !ok && prometheusK8s["additionalAlertmanagerConfigs"] != nil {
+		return kverrors.New("invalid cluster monitoring configuration, additionalAlertmanagerConfigs must be a list")
+	}
+	prometheusK8s["additionalAlertmanagerConfigs"] = append(amConfigs, amConfig)
+
+	labels, ok := prometheusK8s["externalLabels"].(map[string]interface{})
+	if !ok {
+		if prometheusK8s["externalLabels"] != nil {
+			return kverrors.New("invalid cluster monitoring configuration, externalLabels must be a map")
+		}
+		labels = map[string]interface{}{}
+	}
+	for k, v := range externalLabels {
+		labels[k] = v
+	}
+	prometheusK8s["externalLabels"] = labels
+
+	config["prometheusK8s"] = prometheusK8s
+	return nil
+}
@@ -0,0 +1,25 @@
+// Package v1alpha1 contains the subset of the monitoring.coreos.com/v1alpha1
+// API group that is rendered by the addon on clusters running the Prometheus
+// Operator.
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const PrometheusAgentKind = "PrometheusAgent"
+
+// GroupVersion is group version used to register these objects
+var GroupVersion = schema.GroupVersion{Group: "monitoring.coreos.com", Version: "v1alpha1"}
+
+// AddToScheme registers the PrometheusAgent kind of this group-version as an
+// unstructured object. This is enough for the addon-framework to decode the
+// manifests rendered by the metrics chart without depending on the Prometheus
+// Operator module.
+func AddToScheme(s *runtime.Scheme) error {
+	s.AddKnownTypeWithName(GroupVersion.WithKind(PrometheusAgentKind), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(GroupVersion.WithKind(PrometheusAgentKind+"List"), &unstructured.UnstructuredList{})
+	return nil
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	v1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	"github.com/rhobs/multicluster-observability-addon/internal/addon/authentication"
+	"github.com/rhobs/multicluster-observability-addon/internal/manifests"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// authenticationKey holds in a metrics ConfigMap, in YAML, the
+	// authentication type of the remote write destinations by name, e.g.
+	// "hub: mTLS". Unless a tenant secret holds its credentials, the hub
+	// uses the credentials provided by the multicluster observability
+	// operator (MCO) by default, which only its observatorium API trusts.
+	// Clusters without MCO opt into the client certificate generated by the
+	// secrets provider with mTLS.
+	authenticationKey = "authentication"
+	// authenticationCAKey holds in a metrics ConfigMap the CA bundle injected
+	// in the secrets generated for the destinations using mTLS
+	authenticationCAKey = "authenticationCA"
+
+	// annotationTargetName is set on the secrets generated by the secrets
+	// provider with the name of their destination
+	annotationTargetName = "metrics.mcoa.openshift.io/target-name"
+
+	certOrganizationalUnit = "multicluster-observability-addon"
+
+	staticSecretName      = "metrics-static-authentication"
+	staticSecretNamespace = "open-cluster-management"
+)
+
+// buildAuthConfig returns the configuration used to generate the secrets of
+// the remote write destinations. The client certificates are issued for
+// commonName.
+func buildAuthConfig(commonName, ca string) *authentication.Config {
+	return &authentication.Config{
+		StaticAuthConfig: manifests.StaticAuthenticationConfig{
+			ExistingSecret: client.ObjectKey{
+				Name:      staticSecretName,
+				Namespace: staticSecretNamespace,
+			},
+		},
+		MTLSConfig: manifests.MTLSConfig{
+			CAToInject: ca,
+			CommonName: commonName,
+			Subject: &v1.X509Subject{
+				OrganizationalUnits: []string{
+					certOrganizationalUnit,
+				},
+			},
+		},
+	}
+}
+
+// getAuthentication returns the authentication type of the remote write
+// destinations and the CA injected in the mTLS secrets from the metrics
+// configuration data.
+func getAuthentication(configs []configData) (map[authentication.Target]authentication.AuthenticationType, string, error) {
+	targets := map[authentication.Target]authentication.AuthenticationType{}
+	ca := ""
+	for _, config := range configs {
+		if value, ok := config.data[authenticationCAKey]; ok {
+			ca = value
+		}
+
+		raw, ok := config.data[authenticationKey]
+		if !ok {
+			continue
+		}
+		authTypes := map[string]authentication.AuthenticationType{}
+		if err := yaml.Unmarshal([]byte(raw), &authTypes); err != nil {
+			return nil, "", kverrors.Wrap(err, "failed to parse authentication", "name", config.key.Name, "namespace", config.key.Namespace)
+		}
+
+		for name, authType := range authTypes {
+			switch authType {
+			case authentication.MTLS, authentication.Static:
+			case authentication.MCO:
+				if name != hubRemoteWriteName {
+					return nil, "", kverrors.New("only the hub destination supports the MCO authentication", "destination", name)
+				}
+			default:
+				return nil, "", kverrors.New("unsupported authentication type", "destination", name, "type", authType)
+			}
+			targets[authentication.Target(name)] = authType
+		}
+	}
+
+	return targets, ca, nil
+}
+
+// withAuthentication generates the secrets of the remote write destinations
+// configured with an authentication type and configures the destinations
+// with them. It returns the secrets to create on the spoke cluster.
+func withAuthentication(k8sClient client.Client, clusterName string, configs []configData, values []RemoteWriteValue) ([]SecretValue, error) {
+	authTypes, ca, err := getAuthentication(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := map[authentication.Target]*RemoteWriteValue{}
+	for i := range values {
+		destinations[authentication.Target(values[i].Name)] = &values[i]
+	}
+
+	hub := destinations[hubRemoteWriteName]
+	if _, ok := authTypes[hubRemoteWriteName]; !ok && hub != nil && hub.SecretName == "" {
+		authTypes[hubRemoteWriteName] = authentication.MCO
+	}
+
+	targetAuthType := map[authentication.Target]authentication.AuthenticationType{}
+	for target, authType := range authTypes {
+		// The Alertmanager secret is generated with the alert forwarding
+		if target == alertmanagerTarget {
+			continue
+		}
+		if authType == authentication.MCO {
+			if hub != nil {
+				withMCOCredentials(hub)
+			}
+			continue
+		}
+		targetAuthType[target] = authType
+	}
+	if len(targetAuthType) == 0 {
+		return nil, nil
+	}
+	for target := range targetAuthType {
+		destination, ok := destinations[target]
+		if !ok {
+			return nil, kverrors.New("authentication configured for an unknown destination", "destination", target)
+		}
+		if destination.SecretName != "" {
+			return nil, kverrors.New("destination credentials set both by a secret and an authentication type", "destination", target)
+		}
+	}
+
+	secrets, err := fetchAuthenticationSecrets(k8sClient, clusterName, ca, targetAuthType)
+	if err != nil {
+		return nil, err
+	}
+
+	secretValues := make([]SecretValue, 0, len(secrets))
+	for target, secret := range secrets {
+		secret := secret
+		secretValue, err := withRemoteWriteSecret(destinations[target], &secret)
+		if err != nil {
+			return nil, kverrors.Wrap(err, "invalid authentication secret", "destination", target, "name", secret.Name)
+		}
+		secretValues = append(secretValues, *secretValue)
+	}
+	sort.Slice(secretValues, func(i, j int) bool { return secretValues[i].Name < secretValues[j].Name })
+
+	return secretValues, nil
+}
+
+// fetchAuthenticationSecrets generates, with the secrets provider shared by
+// the signals, the secrets of the targets and returns them by target.
+func fetchAuthenticationSecrets(k8sClient client.Client, clusterName, ca string, targetAuthType map[authentication.Target]authentication.AuthenticationType) (map[authentication.Target]corev1.Secret, error) {
+	ctx := context.Background()
+	secretsProvider, err := authentication.NewSecretsProvider(k8sClient, clusterName, addon.Metrics, buildAuthConfig(clusterName, ca))
+	if err != nil {
+		return nil, err
+	}
+
+	targetsSecret, err := secretsProvider.GenerateSecrets(ctx, targetAuthType)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := secretsProvider.FetchSecrets(ctx, targetsSecret, annotationTargetName)
+	if err != nil {
+		return nil, err
+	}
+
+	secretsByTarget := make(map[authentication.Target]corev1.Secret, len(secrets))
+	for _, secret := range secrets {
+		secretsByTarget[authentication.Target(secret.Annotations[annotationTargetName])] = secret
+	}
+	return secretsByTarget, nil
+}
+
+// usesMCOCredentials returns whether any destination still reads the
+// certificates provided by the multicluster observability operator, which
+// then must be mounted in the metrics agent.
+func usesMCOCredentials(values []RemoteWriteValue) bool {
+	for _, value := range values {
+		if value.TLSConfig == nil {
+			continue
+		}
+		for _, file := range []string{value.TLSConfig.CAFile, value.TLSConfig.CertFile, value.TLSConfig.KeyFile} {
+			for _, secretName := range []string{hubCASecretName, hubCertSecretName} {
+				if strings.HasPrefix(file, secretFile(secretName, "")+"/") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configData is the data of a metrics ConfigMap referenced in the configs of
+// the ManagedClusterAddOn
+type configData struct {
+	key  client.ObjectKey
+	data map[string]string
+}
+
+// getConfigData returns the data of the metrics ConfigMaps referenced in the
+// configs of the ManagedClusterAddOn. A ConfigMap with the same name in the
+// namespace of the managed cluster overrides the keys of the referenced one
+// for that cluster only.
+func getConfigData(k8sClient client.Client, mca *addonapiv1alpha1.ManagedClusterAddOn) ([]configData, error) {
+	configs := []configData{}
+	for _, config := range mca.Spec.Configs {
+		if config.ConfigGroupResource.Resource != addon.ConfigMapResource {
+			continue
+		}
+
+		cm := &corev1.ConfigMap{}
+		key := client.ObjectKey{Name: config.Name, Namespace: config.Namespace}
+		if err := k8sClient.Get(context.Background(), key, cm, &client.GetOptions{}); err != nil {
+			return nil, err
+		}
+
+		// Only care about cm's that configure metrics
+		if signal, ok := cm.Labels[addon.SignalLabelKey]; !ok || signal != addon.Metrics.String() {
+			continue
+		}
+
+		data := cm.Data
+		overlayKey := client.ObjectKey{Name: config.Name, Namespace: mca.Namespace}
+		if overlayKey != key {
+			overlay := &corev1.ConfigMap{}
+			err := k8sClient.Get(context.Background(), overlayKey, overlay, &client.GetOptions{})
+			switch {
+			case err == nil:
+				data = mergeData(cm.Data, overlay.Data)
+			case !apierrors.IsNotFound(err):
+				return nil, err
+			}
+		}
+
+		configs = append(configs, configData{key: key, data: data})
+	}
+
+	return configs, nil
+}
+
+func mergeData(base, overlay map[string]string) map[string]string {
+	data := make(map[string]string, len(base)+len(overlay))
+	for k, v := range base {
+		data[k] = v
+	}
+	for k, v := range overlay {
+		data[k] = v
+	}
+	return data
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
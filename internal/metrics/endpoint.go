@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// destinationEndpointValueKey sets the URL of the hub metrics endpoint,
+	// skipping the discovery, where {tenant} is replaced by the tenant of the
+	// managed cluster
+	destinationEndpointValueKey = "metricsDestinationEndpoint"
+	// endpointRefValueKey references, as kind/namespace/name, the Route,
+	// Ingress or LoadBalancer Service exposing the metrics receiver on the hub
+	endpointRefValueKey = "metricsEndpointRef"
+	// endpointPathValueKey sets the path of the hub metrics endpoint, where
+	// {tenant} is replaced by the tenant of the managed cluster
+	endpointPathValueKey = "metricsEndpointPath"
+
+	defaultEndpointRef  = "route/open-cluster-management-observability/observatorium-api"
+	defaultEndpointPath = "/api/metrics/v1/{tenant}/api/v1/receive"
+	tenantPlaceholder   = "{tenant}"
+)
+
+// getDestinationEndpoint returns the URL of the hub metrics endpoint for the
+// tenant. Unless set explicitly, the host is discovered from the Route,
+// Ingress or Service referenced in the AddOnDeploymentConfig, the
+// observatorium-api Route of the multicluster observability operator by
+// default. The hub cluster uses the Service behind them.
+func getDestinationEndpoint(k8sClient client.Client, cluster *clusterv1.ManagedCluster, adoc *addonapiv1alpha1.AddOnDeploymentConfig, tenant string) (string, error) {
+	ref, endpointPath := defaultEndpointRef, defaultEndpointPath
+	if adoc != nil {
+		for _, customVar := range adoc.Spec.CustomizedVariables {
+			switch customVar.Name {
+			case destinationEndpointValueKey:
+				return strings.ReplaceAll(customVar.Value, tenantPlaceholder, tenant), nil
+			case endpointRefValueKey:
+				if customVar.Value != "" {
+					ref = customVar.Value
+				}
+			case endpointPathValueKey:
+				if customVar.Value != "" {
+					endpointPath = customVar.Value
+				}
+			}
+		}
+	}
+
+	if !strings.HasPrefix(endpointPath, "/") {
+		return "", kverrors.New("the hub metrics endpoint path must be absolute", "name", endpointPathValueKey, "value", endpointPath)
+	}
+
+	u, err := addon.DiscoverURL(k8sClient, endpointRefValueKey, ref, addon.IsHubCluster(cluster))
+	if err != nil {
+		return "", err
+	}
+
+	return u.String() + strings.ReplaceAll(endpointPath, tenantPlaceholder, tenant), nil
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+)
+
+const (
+	// federationMatchersKey holds in a metrics ConfigMap the selectors, one
+	// per line, federated in addition to the default ones
+	federationMatchersKey = "federationMatchers"
+	// userWorkloadFederationMatchersKey holds in a metrics ConfigMap the
+	// selectors, one per line, federated from the user workload Prometheus.
+	// The user workload Prometheus is only federated when set.
+	userWorkloadFederationMatchersKey = "userWorkloadFederationMatchers"
+	// federationScrapeIntervalKey holds in a metrics ConfigMap the interval
+	// at which the platform Prometheus is federated
+	federationScrapeIntervalKey = "federationScrapeInterval"
+	// federationSampleLimitKey holds in a metrics ConfigMap the maximum
+	// number of series accepted per scrape of the federate job
+	federationSampleLimitKey = "federationSampleLimit"
+	// federationLabelLimitKey holds in a metrics ConfigMap the maximum number
+	// of labels accepted per series of the federate job
+	federationLabelLimitKey = "federationLabelLimit"
+
+	defaultFederationScrapeInterval = "4m"
+)
+
+// defaultFederationMatchers are the series federated from the platform
+// Prometheus of every managed cluster.
+var defaultFederationMatchers = []string{
+	`{__name__="up"}`,
+	`{__name__=":node_memory_MemAvailable_bytes:sum"}`,
+	`{__name__="cluster:capacity_cpu_cores:sum"}`,
+	`{__name__="cluster:capacity_memory_bytes:sum"}`,
+	`{__name__="cluster:container_cpu_usage:ratio"}`,
+	`{__name__="cluster:container_spec_cpu_shares:ratio"}`,
+	`{__name__="cluster:cpu_usage_cores:sum"}`,
+	`{__name__="cluster:memory_usage:ratio"}`,
+	`{__name__="cluster:memory_usage_bytes:sum"}`,
+	`{__name__="cluster:usage:resources:sum"}`,
+	`{__name__="cluster_infrastructure_provider"}`,
+	`{__name__="cluster_version"}`,
+	`{__name__="cluster_version_payload"}`,
+	`{__name__="container_cpu_cfs_throttled_periods_total"}`,
+	`{__name__="container_memory_cache"}`,
+	`{__name__="container_memory_rss"}`,
+	`{__name__="container_memory_swap"}`,
+	`{__name__="container_memory_working_set_bytes"}`,
+	`{__name__="container_network_receive_bytes_total"}`,
+	`{__name__="container_network_receive_packets_dropped_total"}`,
+	`{__name__="container_network_receive_packets_total"}`,
+	`{__name__="container_network_transmit_bytes_total"}`,
+	`{__name__="container_network_transmit_packets_dropped_total"}`,
+	`{__name__="container_network_transmit_packets_total"}`,
+	`{__name__="haproxy_backend_connections_total"}`,
+	`{__name__="instance:node_cpu_utilisation:rate1m"}`,
+	`{__name__="instance:node_load1_per_cpu:ratio"}`,
+	`{__name__="instance:node_memory_utilisation:ratio"}`,
+	`{__name__="instance:node_network_receive_bytes_excluding_lo:rate1m"}`,
+	`{__name__="instance:node_network_receive_drop_excluding_lo:rate1m",}`,
+	`{__name__="instance:node_network_transmit_bytes_excluding_lo:rate1m"}`,
+	`{__name__="instance:node_network_transmit_drop_excluding_lo:rate1m"}`,
+	`{__name__="instance:node_num_cpu:sum"}`,
+	`{__name__="instance:node_vmstat_pgmajfault:rate1m"}`,
+	`{__name__="instance_device:node_disk_io_time_seconds:rate1m"}`,
+	`{__name__="instance_device:node_disk_io_time_weighted_seconds:rate1m"}`,
+	`{__name__="kube_node_status_allocatable_cpu_cores"}`,
+	`{__name__="kube_node_status_allocatable_memory_bytes"}`,
+	`{__name__="kube_pod_container_resource_limits_cpu_cores"}`,
+	`{__name__="kube_pod_container_resource_limits_memory_bytes"}`,
+	`{__name__="kube_pod_container_resource_requests_cpu_cores"}`,
+	`{__name__="kube_pod_container_resource_requests_memory_bytes"}`,
+	`{__name__="kube_pod_info"}`,
+	`{__name__="kube_resourcequota"}`,
+	`{__name__="machine_cpu_cores"}`,
+	`{__name__="machine_memory_bytes"}`,
+	`{__name__="mixin_pod_workload"}`,
+	`{__name__="node_cpu_seconds_total"}`,
+	`{__name__="node_filesystem_avail_bytes"}`,
+	`{__name__="node_filesystem_size_bytes"}`,
+	`{__name__="node.oc_memory_MemAvailable_bytes"}`,
+	`{__name__="node_namespace_pod_container:container_cpu_usage_seconds_total:sum_rate"}`,
+	`{__name__="node_namespace_pod_container:container_memory_cache"}`,
+	`{__name__="node_namespace_pod_container:container_memory_rss"}`,
+	`{__name__="node_namespace_pod_container:container_memory_swap"}`,
+	`{__name__="node_namespace_pod_container:container_memory_working_set_bytes"}`,
+	`{__name__="node_netstat_Tcp_OutSegs"}`,
+	`{__name__="node_netstat_Tcp_RetransSegs"}`,
+	`{__name__="node_netstat_TcpExt_TCPSynRetrans"}`,
+}
+
+type FederationValues struct {
+	ScrapeInterval       string          `json:"scrapeInterval"`
+	Matchers             []string        `json:"matchers"`
+	UserWorkloadMatchers []string        `json:"userWorkloadMatchers"`
+	MetricRelabelConfigs []RelabelConfig `json:"metricRelabelConfigs"`
+	SampleLimit          int             `json:"sampleLimit"`
+	LabelLimit           int             `json:"labelLimit"`
+}
+
+// getFederationValues builds the federation job configuration from the
+// defaults and the metrics configuration data.
+func getFederationValues(configs []configData) (FederationValues, error) {
+	values := FederationValues{
+		ScrapeInterval:       defaultFederationScrapeInterval,
+		Matchers:             append([]string{}, defaultFederationMatchers...),
+		UserWorkloadMatchers: []string{},
+		MetricRelabelConfigs: []RelabelConfig{},
+	}
+
+	for _, config := range configs {
+		if err := applyFederationData(&values, config.data); err != nil {
+			return values, kverrors.Wrap(err, "invalid federation configuration", "name", config.key.Name, "namespace", config.key.Namespace)
+		}
+	}
+
+	return values, nil
+}
+
+func applyFederationData(values *FederationValues, data map[string]string) error {
+	if interval, ok := data[federationScrapeIntervalKey]; ok {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return kverrors.Wrap(err, "failed to parse scrape interval", "key", federationScrapeIntervalKey)
+		}
+		if d <= 0 {
+			return kverrors.New("scrape interval must be positive", "key", federationScrapeIntervalKey, "value", interval)
+		}
+		values.ScrapeInterval = interval
+	}
+
+	var err error
+	values.Matchers, err = appendMatchers(values.Matchers, federationMatchersKey, data[federationMatchersKey])
+	if err != nil {
+		return err
+	}
+	values.UserWorkloadMatchers, err = appendMatchers(values.UserWorkloadMatchers, userWorkloadFederationMatchersKey, data[userWorkloadFederationMatchersKey])
+	if err != nil {
+		return err
+	}
+
+	if raw, ok := data[metricRelabelConfigsKey]; ok {
+		configs, err := parseRelabelConfigs(metricRelabelConfigsKey, raw)
+		if err != nil {
+			return err
+		}
+		values.MetricRelabelConfigs = append(values.MetricRelabelConfigs, configs...)
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value *int
+	}{
+		{key: federationSampleLimitKey, value: &values.SampleLimit},
+		{key: federationLabelLimitKey, value: &values.LabelLimit},
+	} {
+		key := limit.key
+		raw, ok := data[key]
+		if !ok {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return kverrors.Wrap(err, "failed to parse limit", "key", key)
+		}
+		if v < 0 {
+			return kverrors.New("limit must not be negative", "key", key, "value", raw)
+		}
+		*limit.value = v
+	}
+
+	return nil
+}
+
+// appendMatchers appends to matchers the selectors, one per line, of raw.
+// Empty lines and lines starting with # are ignored.
+func appendMatchers(matchers []string, key, raw string) ([]string, error) {
+	for _, line := range strings.Split(raw, "\n") {
+		matcher := strings.TrimSpace(line)
+		if matcher == "" || strings.HasPrefix(matcher, "#") {
+			continue
+		}
+		if !strings.HasPrefix(matcher, "{") || !strings.HasSuffix(matcher, "}") {
+			return nil, kverrors.New("selector must be enclosed in braces", "key", key, "selector", matcher)
+		}
+		matchers = appendUnique(matchers, matcher)
+	}
+	return matchers, nil
+}
@@ -1,58 +1,239 @@
 package metrics
 
 import (
-	"context"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
-	routev1 "github.com/openshift/api/route/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"github.com/ViaQ/logerr/v2/kverrors"
+	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	defaultPrometheusImage = "quay.io/prometheus/prometheus:v2.48.1"
+
+	// namespaceValueKey sets the namespace where the metrics agent is
+	// deployed on the spoke cluster
+	namespaceValueKey = "metricsNamespace"
+	defaultNamespace  = "open-cluster-management-addon-observability"
+
+	// clusterLabelsValueKey selects, as a comma separated list, the labels of
+	// the ManagedCluster added as external labels to the shipped series
+	clusterLabelsValueKey = "metricsClusterLabels"
+
+	// prometheusAgentValueKey selects whether the metrics agent is deployed
+	// as a Prometheus Operator PrometheusAgent instead of a Deployment
+	prometheusAgentValueKey = "metricsPrometheusAgent"
+	// prometheusAgentClaim is the ClusterClaim reporting, when set to true,
+	// that the PrometheusAgent CRD is served by the managed cluster
+	prometheusAgentClaim = "prometheusagents.monitoring.coreos.com"
+
+	externalLabelClusterName = "cluster"
+	externalLabelClusterID   = "clusterID"
+)
+
+var externalLabelReplace = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
 type MetricsValues struct {
 	Enabled bool `json:"enabled"`
 	// TODO: revert this hack to the official way as recommended by the docs.
 	// See https://open-cluster-management.io/developer-guides/addon/#values-definition.
-	AddonInstallNamespace string `json:"addonInstallNamespace"`
-	DestinationEndpoint   string `json:"destinationEndpoint"`
+	AddonInstallNamespace string                `json:"addonInstallNamespace"`
+	Namespace             string                `json:"namespace"`
+	Image                 string                `json:"image"`
+	Hub                   bool                  `json:"hub"`
+	PrometheusAgent       bool                  `json:"prometheusAgent"`
+	Agent                 AgentValues           `json:"agent"`
+	Storage               StorageValues         `json:"storage"`
+	Federation            FederationValues      `json:"federation"`
+	Monitors              MonitorsValues        `json:"monitors"`
+	ExternalLabels        map[string]string     `json:"externalLabels"`
+	RemoteWrite           []RemoteWriteValue    `json:"remoteWrite"`
+	MCOCredentials        bool                  `json:"mcoCredentials"`
+	AlertForwarding       AlertForwardingValues `json:"alertForwarding"`
+	Secrets               []SecretValue         `json:"secrets"`
 }
 
 func GetValuesFunc(
 	k8sClient client.Client,
-	_ *clusterv1.ManagedCluster,
+	cluster *clusterv1.ManagedCluster,
 	mca *addonapiv1alpha1.ManagedClusterAddOn,
 	adoc *addonapiv1alpha1.AddOnDeploymentConfig,
 ) (MetricsValues, error) {
-	endpoint, err := getDestinationEndpoint(k8sClient, adoc)
+	prometheusAgent, err := usePrometheusAgent(cluster, adoc)
+	if err != nil {
+		return MetricsValues{}, err
+	}
+	storage, err := getStorageValues(adoc)
+	if err != nil {
+		return MetricsValues{}, err
+	}
+	configs, err := getConfigData(k8sClient, mca)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics configuration: %w", err)
+	}
+	agent, err := getAgentValues(configs)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics agent configuration: %w", err)
+	}
+	monitors, monitorsConfigured, err := getMonitorsValues(configs)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics monitors configuration: %w", err)
+	}
+	if monitorsConfigured && !prometheusAgent {
+		return MetricsValues{}, kverrors.New("scraping ServiceMonitors and PodMonitors requires the metrics agent to be deployed as a PrometheusAgent")
+	}
+	// Identical agents would send every series several times. Only the
+	// Prometheus operator tells the replicas apart, with the
+	// prometheus_replica external label.
+	if agent.Replicas > 1 && !prometheusAgent {
+		return MetricsValues{}, kverrors.New("several metrics agent replicas require the metrics agent to be deployed as a PrometheusAgent", "replicas", agent.Replicas)
+	}
+	tenant, err := getTenant(cluster, adoc, configs)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics tenant: %w", err)
+	}
+	endpoint, err := getDestinationEndpoint(k8sClient, cluster, adoc, tenant)
 	if err != nil {
 		return MetricsValues{}, fmt.Errorf("failed to get metrics destination endpoint: %w", err)
 	}
+	hub := buildHubRemoteWrite(endpoint)
+	hubSecret, err := withTenantCredentials(k8sClient, mca.Namespace, tenant, &hub)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics tenant credentials: %w", err)
+	}
+	federation, err := getFederationValues(configs)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics federation configuration: %w", err)
+	}
+	remoteWrite, secrets, err := getRemoteWriteValues(k8sClient, mca.Namespace, hub, configs)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics remote write configuration: %w", err)
+	}
+	if hubSecret != nil {
+		secrets = append([]SecretValue{*hubSecret}, secrets...)
+	}
+	authSecrets, err := withAuthentication(k8sClient, mca.Namespace, configs, remoteWrite)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics authentication: %w", err)
+	}
+	secrets = append(secrets, authSecrets...)
+	externalLabels := getExternalLabels(cluster, adoc)
+	alertForwarding, err := getAlertForwardingValues(k8sClient, cluster, mca.Namespace, adoc, configs, externalLabels)
+	if err != nil {
+		return MetricsValues{}, fmt.Errorf("failed to get metrics alert forwarding configuration: %w", err)
+	}
+	namespace, err := getNamespace(adoc)
+	if err != nil {
+		return MetricsValues{}, err
+	}
 	values := MetricsValues{
 		Enabled:               true,
 		AddonInstallNamespace: mca.Spec.InstallNamespace,
-		DestinationEndpoint:   endpoint,
+		Namespace:             namespace,
+		Image:                 defaultPrometheusImage,
+		Hub:                   addon.IsHubCluster(cluster),
+		PrometheusAgent:       prometheusAgent,
+		Agent:                 agent,
+		Storage:               storage,
+		Federation:            federation,
+		Monitors:              monitors,
+		ExternalLabels:        externalLabels,
+		RemoteWrite:           remoteWrite,
+		MCOCredentials:        usesMCOCredentials(remoteWrite),
+		AlertForwarding:       alertForwarding,
+		Secrets:               secrets,
+	}
+	if adoc != nil {
+		values.Image = addonfactory.OverrideImage(adoc.Spec.Registries, defaultPrometheusImage)
 	}
 	return values, nil
 }
 
-func getDestinationEndpoint(k8sClient client.Client, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (string, error) {
+// getNamespace returns the namespace of the metrics agent on the spoke
+// cluster. The namespace is created with the agent unless it is the install
+// namespace of the addon.
+func getNamespace(adoc *addonapiv1alpha1.AddOnDeploymentConfig) (string, error) {
 	if adoc != nil {
 		for _, customVar := range adoc.Spec.CustomizedVariables {
-			if customVar.Name == "metricsDestinationEndpoint" {
-				return customVar.Value, nil
+			if customVar.Name != namespaceValueKey || customVar.Value == "" {
+				continue
+			}
+			if errs := validation.IsDNS1123Label(customVar.Value); len(errs) > 0 {
+				return "", kverrors.New("invalid customized variable", "name", namespaceValueKey, "value", customVar.Value, "reason", strings.Join(errs, ", "))
 			}
+			return customVar.Value, nil
 		}
 	}
+	return defaultNamespace, nil
+}
 
-	route := &routev1.Route{}
-	err := k8sClient.Get(context.TODO(), types.NamespacedName{
-		Namespace: "open-cluster-management-observability",
-		Name:      "observatorium-api",
-	}, route)
-	if err != nil {
-		return "", err
+// usePrometheusAgent returns whether the metrics agent is deployed as a
+// PrometheusAgent. The hub can't inspect the CRDs of the managed cluster, so
+// unless the AddOnDeploymentConfig decides explicitly, the PrometheusAgent is
+// only used when the managed cluster reports the CRD with a ClusterClaim.
+func usePrometheusAgent(cluster *clusterv1.ManagedCluster, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (bool, error) {
+	if adoc != nil {
+		for _, customVar := range adoc.Spec.CustomizedVariables {
+			if customVar.Name != prometheusAgentValueKey {
+				continue
+			}
+			enabled, err := strconv.ParseBool(customVar.Value)
+			if err != nil {
+				return false, kverrors.Wrap(err, "invalid customized variable", "name", prometheusAgentValueKey, "value", customVar.Value)
+			}
+			return enabled, nil
+		}
+	}
+
+	if cluster == nil {
+		return false, nil
 	}
-	return fmt.Sprintf("https://%s/api/metrics/v1/default/api/v1/receive", route.Spec.Host), nil
+	for _, claim := range cluster.Status.ClusterClaims {
+		if claim.Name == prometheusAgentClaim {
+			enabled, _ := strconv.ParseBool(claim.Value)
+			return enabled, nil
+		}
+	}
+	return false, nil
+}
+
+// getExternalLabels returns the labels identifying the managed cluster on
+// every series shipped by the metrics agent.
+func getExternalLabels(cluster *clusterv1.ManagedCluster, adoc *addonapiv1alpha1.AddOnDeploymentConfig) map[string]string {
+	labels := map[string]string{}
+	if cluster == nil {
+		return labels
+	}
+
+	if adoc != nil {
+		for _, customVar := range adoc.Spec.CustomizedVariables {
+			if customVar.Name != clusterLabelsValueKey {
+				continue
+			}
+			for _, key := range strings.Split(customVar.Value, ",") {
+				key = strings.TrimSpace(key)
+				value, ok := cluster.Labels[key]
+				if key == "" || !ok {
+					continue
+				}
+				labels[externalLabelReplace.ReplaceAllString(key, "_")] = value
+			}
+		}
+	}
+
+	// The identity of the cluster always takes precedence over the selected
+	// labels
+	labels[externalLabelClusterName] = cluster.Name
+	if id, ok := addon.ClusterID(cluster); ok {
+		labels[externalLabelClusterID] = id
+	}
+
+	return labels
 }
@@ -1,16 +1,27 @@
 package metrics
 
 import (
+	"context"
 	"testing"
 
 	v1 "k8s.io/api/apps/v1"
 
 	"github.com/rhobs/multicluster-observability-addon/internal/addon"
+	monitoringv1alpha1 "github.com/rhobs/multicluster-observability-addon/internal/metrics/apis/monitoring/v1alpha1"
 
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	routev1 "github.com/openshift/api/route/v1"
 	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
@@ -20,11 +31,15 @@ import (
 	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 var (
 	_ = operatorsv1.AddToScheme(scheme.Scheme)
 	_ = operatorsv1alpha1.AddToScheme(scheme.Scheme)
+	_ = routev1.AddToScheme(scheme.Scheme)
+	_ = monitoringv1alpha1.AddToScheme(scheme.Scheme)
+	_ = certmanagerv1.AddToScheme(scheme.Scheme)
 )
 
 func testingGetValues(k8s client.Client) addonfactory.GetValuesFunc {
@@ -91,13 +106,1631 @@ func Test_GenerateManagedClusterResources(t *testing.T) {
 
 	objects, err := metricsAgentAddon.Manifests(managedCluster, managedClusterAddOn)
 	require.NoError(t, err)
-	require.Equal(t, 5, len(objects))
+	require.Equal(t, 7, len(objects))
 
 	for _, obj := range objects {
 		switch obj := obj.(type) {
 		// TODO: check the generated objects
 		case *v1.Deployment:
 			require.Equal(t, obj.Name, "metrics-addon-agent")
+		case *corev1.ConfigMap:
+			if obj.Name == "metrics-addon-agent-serving-certs-ca-bundle" {
+				require.Equal(t, "true", obj.Annotations["service.beta.openshift.io/inject-cabundle"])
+			}
 		}
 	}
 }
+
+func Test_GetFederationValues(t *testing.T) {
+	hubConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-federation",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"federationMatchers":       "{__name__=\"up\"}\n# etcd\n{__name__=~\"etcd_.*\"}\n",
+			"federationScrapeInterval": "2m",
+		},
+	}
+	clusterConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-federation",
+			Namespace: "cluster1",
+		},
+		Data: map[string]string{
+			"federationScrapeInterval": "30s",
+		},
+	}
+	invalidConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-invalid",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"federationScrapeInterval": "1d",
+		},
+	}
+
+	invalidLimitConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-invalid-limit",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"federationSampleLimit": "-1",
+		},
+	}
+
+	for _, tc := range []struct {
+		name           string
+		configs        []string
+		objects        []client.Object
+		scrapeInterval string
+		extraMatchers  []string
+		wantErr        bool
+	}{
+		{
+			name:           "Defaults",
+			scrapeInterval: "4m",
+		},
+		{
+			name:           "HubConfigMap",
+			configs:        []string{"metrics-federation"},
+			objects:        []client.Object{hubConfig},
+			scrapeInterval: "2m",
+			extraMatchers:  []string{`{__name__=~"etcd_.*"}`},
+		},
+		{
+			name:           "ClusterOverride",
+			configs:        []string{"metrics-federation"},
+			objects:        []client.Object{hubConfig, clusterConfig},
+			scrapeInterval: "30s",
+			extraMatchers:  []string{`{__name__=~"etcd_.*"}`},
+		},
+		{
+			name:    "InvalidSampleLimit",
+			configs: []string{"metrics-invalid-limit"},
+			objects: []client.Object{invalidLimitConfig},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidScrapeInterval",
+			configs: []string{"metrics-invalid"},
+			objects: []client.Object{invalidConfig},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mca := addontesting.NewAddon("test", "cluster1")
+			for _, name := range tc.configs {
+				mca.Spec.Configs = append(mca.Spec.Configs, addonapiv1alpha1.AddOnConfig{
+					ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+						Resource: addon.ConfigMapResource,
+					},
+					ConfigReferent: addonapiv1alpha1.ConfigReferent{
+						Name:      name,
+						Namespace: "open-cluster-management",
+					},
+				})
+			}
+
+			k8s := fake.NewClientBuilder().WithObjects(tc.objects...).Build()
+			configs, err := getConfigData(k8s, mca)
+			require.NoError(t, err)
+
+			values, err := getFederationValues(configs)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.scrapeInterval, values.ScrapeInterval)
+			require.Equal(t, append(append([]string{}, defaultFederationMatchers...), tc.extraMatchers...), values.Matchers)
+		})
+	}
+}
+
+func Test_GetExternalLabels(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster1")
+	cluster.Labels = map[string]string{
+		"clusterID":    "label-id",
+		"cloud":        "Amazon",
+		"region.io/az": "us-east-1a",
+		"cluster":      "not-the-name",
+	}
+
+	for _, tc := range []struct {
+		name   string
+		claims []clusterv1.ManagedClusterClaim
+		adoc   *addonapiv1alpha1.AddOnDeploymentConfig
+		labels map[string]string
+	}{
+		{
+			name: "ClusterIDLabel",
+			labels: map[string]string{
+				"cluster":   "cluster1",
+				"clusterID": "label-id",
+			},
+		},
+		{
+			name: "ClusterIDClaim",
+			claims: []clusterv1.ManagedClusterClaim{
+				{Name: "id.k8s.io", Value: "claim-id"},
+			},
+			labels: map[string]string{
+				"cluster":   "cluster1",
+				"clusterID": "claim-id",
+			},
+		},
+		{
+			name: "SelectedLabels",
+			adoc: &addonapiv1alpha1.AddOnDeploymentConfig{
+				Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+					CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+						{Name: "metricsClusterLabels", Value: "cloud, region.io/az,cluster,missing"},
+					},
+				},
+			},
+			labels: map[string]string{
+				"cluster":      "cluster1",
+				"clusterID":    "label-id",
+				"cloud":        "Amazon",
+				"region_io_az": "us-east-1a",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := cluster.DeepCopy()
+			cluster.Status.ClusterClaims = tc.claims
+			require.Equal(t, tc.labels, getExternalLabels(cluster, tc.adoc))
+		})
+	}
+}
+
+func Test_GenerateManagedClusterResources_RemoteWrite(t *testing.T) {
+	managedCluster := addontesting.NewManagedCluster("cluster1")
+	managedClusterAddOn := addontesting.NewAddon("test", "cluster1")
+	managedClusterAddOn.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-remote-write",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-remote-write",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"remoteWrite": `
+- name: hub
+  queueConfig:
+    maxShards: 10
+- name: regional
+  url: https://thanos.example.com/api/v1/receive
+  secretName: regional-credentials
+  queueConfig:
+    capacity: 10000
+    maxSamplesPerSend: 2000
+    batchSendDeadline: 10s
+  writeRelabelConfigs:
+  - sourceLabels: [__name__]
+    regex: "up|cluster_version"
+    action: keep
+`,
+			"writeRelabelConfigs": `
+- action: labeldrop
+  regex: pod_template_hash
+`,
+			"metricRelabelConfigs": `
+- sourceLabels: [__name__, container]
+  regex: "container_memory_rss;POD"
+  action: drop
+`,
+			"federationSampleLimit": "50000",
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "regional-credentials",
+			Namespace: "cluster1",
+		},
+		Data: map[string][]byte{
+			"username": []byte("user"),
+			"password": []byte("pass"),
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route, config, secret).Build()
+
+	metricsAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, "manifests/charts/mcoa/charts/metrics").
+		WithGetValuesFuncs(testingGetValues(k8s)).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(scheme.Scheme).
+		BuildHelmAgentAddon()
+	require.NoError(t, err)
+
+	objects, err := metricsAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+
+	var (
+		deployment *v1.Deployment
+		configMap  *corev1.ConfigMap
+		secrets    []*corev1.Secret
+	)
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *v1.Deployment:
+			deployment = obj
+		case *corev1.ConfigMap:
+			if obj.Name == "prometheus-agent-conf" {
+				configMap = obj
+			}
+		case *corev1.Secret:
+			secrets = append(secrets, obj)
+		}
+	}
+	require.NotNil(t, deployment)
+	require.NotNil(t, configMap)
+	// The hub destination is authenticated with the certificates of the
+	// multicluster observability operator by default
+	require.Len(t, secrets, 1)
+	require.Equal(t, "metrics-remote-write-regional", secrets[0].Name)
+	require.Equal(t, secret.Data, secrets[0].Data)
+	require.Contains(t, deployment.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      "metrics-remote-write-regional",
+		ReadOnly:  true,
+		MountPath: "/etc/prometheus/secrets/metrics-remote-write-regional",
+	})
+	require.Contains(t, deployment.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      "observability-managed-cluster-certs",
+		ReadOnly:  true,
+		MountPath: "/etc/prometheus/secrets/observability-managed-cluster-certs",
+	})
+	require.Equal(t, int32(1), *deployment.Spec.Replicas)
+	require.Equal(t, defaultAgentResources(), deployment.Spec.Template.Spec.Containers[0].Resources)
+	require.Contains(t, deployment.Spec.Template.Spec.Containers[0].Args, "--log.level=info")
+
+	prometheusConfig := struct {
+		ScrapeConfigs []map[string]interface{} `json:"scrape_configs"`
+		RemoteWrite   []map[string]interface{} `json:"remote_write"`
+	}{}
+	require.NoError(t, yaml.Unmarshal([]byte(configMap.Data["prometheus.yml"]), &prometheusConfig))
+	require.Len(t, prometheusConfig.ScrapeConfigs, 1)
+	require.Len(t, prometheusConfig.RemoteWrite, 2)
+
+	federate := prometheusConfig.ScrapeConfigs[0]
+	require.Equal(t, float64(50000), federate["sample_limit"])
+	require.NotContains(t, federate, "label_limit")
+	require.Equal(t, []interface{}{
+		map[string]interface{}{
+			"source_labels": []interface{}{"__name__", "container"},
+			"regex":         "container_memory_rss;POD",
+			"action":        "drop",
+		},
+	}, federate["metric_relabel_configs"])
+
+	labelDrop := map[string]interface{}{
+		"regex":  "pod_template_hash",
+		"action": "labeldrop",
+	}
+
+	hub := prometheusConfig.RemoteWrite[0]
+	require.Equal(t, "https://observatorium.example.com/api/metrics/v1/default/api/v1/receive", hub["url"])
+	require.Equal(t, map[string]interface{}{
+		"ca_file":   "/etc/prometheus/secrets/observability-managed-cluster-certs/ca.crt",
+		"cert_file": "/etc/prometheus/secrets/observability-controller-open-cluster-management.io-observability-signer-client-cert/tls.crt",
+		"key_file":  "/etc/prometheus/secrets/observability-controller-open-cluster-management.io-observability-signer-client-cert/tls.key",
+	}, hub["tls_config"])
+	require.Equal(t, map[string]interface{}{"max_shards": float64(10)}, hub["queue_config"])
+	require.Equal(t, []interface{}{labelDrop}, hub["write_relabel_configs"])
+
+	regional := prometheusConfig.RemoteWrite[1]
+	require.Equal(t, "https://thanos.example.com/api/v1/receive", regional["url"])
+	require.Equal(t, map[string]interface{}{
+		"username_file": "/etc/prometheus/secrets/metrics-remote-write-regional/username",
+		"password_file": "/etc/prometheus/secrets/metrics-remote-write-regional/password",
+	}, regional["basic_auth"])
+	require.Equal(t, map[string]interface{}{
+		"capacity":             float64(10000),
+		"max_samples_per_send": float64(2000),
+		"batch_send_deadline":  "10s",
+	}, regional["queue_config"])
+	require.Equal(t, []interface{}{
+		labelDrop,
+		map[string]interface{}{
+			"source_labels": []interface{}{"__name__"},
+			"regex":         "up|cluster_version",
+			"action":        "keep",
+		},
+	}, regional["write_relabel_configs"])
+}
+
+func Test_GetRemoteWriteValues_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		remoteWrite string
+	}{
+		{
+			name:        "MissingURL",
+			remoteWrite: "- name: regional",
+		},
+		{
+			name:        "InvalidName",
+			remoteWrite: "- name: Regional_Store\n  url: https://thanos.example.com",
+		},
+		{
+			name:        "DuplicateName",
+			remoteWrite: "- name: regional\n  url: https://a.example.com\n- name: regional\n  url: https://b.example.com",
+		},
+		{
+			name:        "HubWithURL",
+			remoteWrite: "- name: hub\n  url: https://a.example.com",
+		},
+		{
+			name:        "InvalidQueueDuration",
+			remoteWrite: "- name: regional\n  url: https://a.example.com\n  queueConfig:\n    batchSendDeadline: soon",
+		},
+		{
+			name:        "UnknownRelabelAction",
+			remoteWrite: "- name: regional\n  url: https://a.example.com\n  writeRelabelConfigs:\n  - action: forget",
+		},
+		{
+			name:        "InvalidRelabelRegex",
+			remoteWrite: "- name: regional\n  url: https://a.example.com\n  writeRelabelConfigs:\n  - action: drop\n    regex: \"(\"",
+		},
+		{
+			name:        "PrometheusRelabelFields",
+			remoteWrite: "- name: regional\n  url: https://a.example.com\n  writeRelabelConfigs:\n  - source_labels: [pod]\n    target_label: pod_name",
+		},
+		{
+			name:        "UnknownField",
+			remoteWrite: "- name: regional\n  url: https://a.example.com\n  secret_name: regional",
+		},
+		{
+			name:        "ReplaceWithoutTarget",
+			remoteWrite: "- name: hub\n  writeRelabelConfigs:\n  - sourceLabels: [pod]",
+		},
+		{
+			name:        "MissingSecret",
+			remoteWrite: "- name: regional\n  url: https://a.example.com\n  secretName: missing",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configs := []configData{
+				{
+					key:  client.ObjectKey{Name: "metrics", Namespace: "open-cluster-management"},
+					data: map[string]string{"remoteWrite": tc.remoteWrite},
+				},
+			}
+			k8s := fake.NewClientBuilder().Build()
+			_, _, err := getRemoteWriteValues(k8s, "cluster1", buildHubRemoteWrite("https://hub.example.com"), configs)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_ParseRelabelConfigs(t *testing.T) {
+	configs, err := parseRelabelConfigs("metricRelabelConfigs", "- sourceLabels: [pod]\n  targetLabel: pod_name")
+	require.NoError(t, err)
+	require.Equal(t, []RelabelConfig{{SourceLabels: []string{"pod"}, TargetLabel: "pod_name"}}, configs)
+
+	// The snake case fields of the Prometheus configuration aren't silently
+	// dropped
+	_, err = parseRelabelConfigs("metricRelabelConfigs", "- source_labels: [pod]\n  target_label: pod_name")
+	require.Error(t, err)
+}
+
+func Test_GetTenant(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster1")
+	cluster.Labels = map[string]string{
+		"cluster.open-cluster-management.io/clusterset": "team-a",
+	}
+	tenantLabel := &addonapiv1alpha1.AddOnDeploymentConfig{
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsTenantLabel", Value: "cluster.open-cluster-management.io/clusterset"},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		adoc    *addonapiv1alpha1.AddOnDeploymentConfig
+		configs []configData
+		tenant  string
+		wantErr bool
+	}{
+		{
+			name:   "Default",
+			tenant: "default",
+		},
+		{
+			name:   "ClusterSetLabel",
+			adoc:   tenantLabel,
+			tenant: "team-a",
+		},
+		{
+			name: "ConfigMapTakesPrecedence",
+			adoc: tenantLabel,
+			configs: []configData{
+				{data: map[string]string{"tenant": "team-b"}},
+			},
+			tenant: "team-b",
+		},
+		{
+			name: "InvalidTenant",
+			configs: []configData{
+				{data: map[string]string{"tenant": "Team B"}},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tenant, err := getTenant(cluster, tc.adoc, tc.configs)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.tenant, tenant)
+		})
+	}
+}
+
+func Test_GetValuesFunc_TenantCredentials(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster1")
+	mca := addontesting.NewAddon("test", "cluster1")
+	mca.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-tenant",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-tenant",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"tenant": "team-a",
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-tenant-team-a",
+			Namespace: "cluster1",
+		},
+		Data: map[string][]byte{
+			"token": []byte("secret-token"),
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route, config, secret).Build()
+
+	values, err := GetValuesFunc(k8s, cluster, mca, nil)
+	require.NoError(t, err)
+	require.Len(t, values.RemoteWrite, 1)
+
+	hub := values.RemoteWrite[0]
+	require.Equal(t, "https://observatorium.example.com/api/metrics/v1/team-a/api/v1/receive", hub.URL)
+	require.Equal(t, "/etc/prometheus/secrets/metrics-remote-write-hub/token", hub.BearerTokenFile)
+	require.Nil(t, hub.TLSConfig)
+	require.Equal(t, "metrics-remote-write-hub", hub.SecretName)
+	require.Len(t, values.Secrets, 1)
+	require.Equal(t, "metrics-remote-write-hub", values.Secrets[0].Name)
+	require.False(t, values.MCOCredentials)
+}
+
+func Test_GetValuesFunc_MTLSAuthentication(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster1")
+	mca := addontesting.NewAddon("test", "cluster1")
+	mca.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-auth",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-auth",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"authentication":   "hub: mTLS\n",
+			"authenticationCA": "hub-ca",
+		},
+	}
+	// The secret cert-manager issues for the Certificate of the hub
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-hub-auth",
+			Namespace: "cluster1",
+		},
+		Data: map[string][]byte{
+			"ca.crt":  []byte("issuer-ca"),
+			"tls.crt": []byte("cert"),
+			"tls.key": []byte("key"),
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route, config, secret).Build()
+
+	values, err := GetValuesFunc(k8s, cluster, mca, nil)
+	require.NoError(t, err)
+	require.Len(t, values.RemoteWrite, 1)
+
+	hub := values.RemoteWrite[0]
+	require.Equal(t, "metrics-remote-write-hub", hub.SecretName)
+	require.Equal(t, &RemoteWriteTLSValue{
+		CAFile:   "/etc/prometheus/secrets/metrics-remote-write-hub/ca-bundle.crt",
+		CertFile: "/etc/prometheus/secrets/metrics-remote-write-hub/tls.crt",
+		KeyFile:  "/etc/prometheus/secrets/metrics-remote-write-hub/tls.key",
+	}, hub.TLSConfig)
+	require.False(t, values.MCOCredentials)
+	require.Len(t, values.Secrets, 1)
+	require.Contains(t, values.Secrets[0].Data, "ca-bundle.crt")
+
+	certificate := &certmanagerv1.Certificate{}
+	err = k8s.Get(context.Background(), client.ObjectKey{Name: "metrics-hub-auth-cert", Namespace: "cluster1"}, certificate)
+	require.NoError(t, err)
+	require.Equal(t, "cluster1", certificate.Spec.CommonName)
+	require.Equal(t, "metrics-hub-auth", certificate.Spec.SecretName)
+}
+
+func Test_GetValuesFunc_MCOAuthentication(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster1")
+	mca := addontesting.NewAddon("test", "cluster1")
+	mca.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-auth",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-auth",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"authentication": "hub: MCO\n",
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route, config).Build()
+
+	values, err := GetValuesFunc(k8s, cluster, mca, nil)
+	require.NoError(t, err)
+	require.Len(t, values.RemoteWrite, 1)
+	require.Equal(t, &RemoteWriteTLSValue{
+		CAFile:   "/etc/prometheus/secrets/observability-managed-cluster-certs/ca.crt",
+		CertFile: "/etc/prometheus/secrets/observability-controller-open-cluster-management.io-observability-signer-client-cert/tls.crt",
+		KeyFile:  "/etc/prometheus/secrets/observability-controller-open-cluster-management.io-observability-signer-client-cert/tls.key",
+	}, values.RemoteWrite[0].TLSConfig)
+	require.True(t, values.MCOCredentials)
+	require.Empty(t, values.Secrets)
+}
+
+func Test_GetAuthentication_Invalid(t *testing.T) {
+	key := client.ObjectKey{Name: "metrics-auth", Namespace: "open-cluster-management"}
+	for _, tc := range []struct {
+		name string
+		data string
+	}{
+		{name: "UnsupportedType", data: "hub: ManagedAuthentication"},
+		{name: "MCOForOtherDestination", data: "regional: MCO"},
+		{name: "Malformed", data: "- hub"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := getAuthentication([]configData{{key: key, data: map[string]string{"authentication": tc.data}}})
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_GenerateManagedClusterResources_PrometheusAgent(t *testing.T) {
+	managedCluster := addontesting.NewManagedCluster("cluster1")
+	managedCluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{
+		{Name: "prometheusagents.monitoring.coreos.com", Value: "true"},
+	}
+	managedClusterAddOn := addontesting.NewAddon("test", "cluster1")
+	managedClusterAddOn.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-remote-write",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-remote-write",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"remoteWrite": `
+- name: regional
+  url: https://thanos.example.com/api/v1/receive
+  secretName: regional-credentials
+  queueConfig:
+    maxShards: 20
+  writeRelabelConfigs:
+  - action: labeldrop
+    regex: pod_template_hash
+`,
+			"serviceMonitorSelector": `
+matchLabels:
+  observability.example.com/scrape: "true"
+`,
+			"monitorNamespaceSelector":       "{}",
+			"userWorkloadFederationMatchers": `{__name__=~"app_.*"}`,
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "regional-credentials",
+			Namespace: "cluster1",
+		},
+		Data: map[string][]byte{
+			"username": []byte("user"),
+			"password": []byte("pass"),
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route, config, secret).Build()
+
+	metricsAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, "manifests/charts/mcoa/charts/metrics").
+		WithGetValuesFuncs(testingGetValues(k8s)).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(scheme.Scheme).
+		BuildHelmAgentAddon()
+	require.NoError(t, err)
+
+	objects, err := metricsAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+
+	var (
+		prometheusAgent *unstructured.Unstructured
+		scrapeConfigs   *corev1.Secret
+	)
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *v1.Deployment:
+			require.Fail(t, "unexpected deployment", obj.Name)
+		case *corev1.ConfigMap:
+			// The Prometheus Operator generates the configuration itself
+			require.Equal(t, "metrics-addon-agent-serving-certs-ca-bundle", obj.Name)
+		case *corev1.Secret:
+			if obj.Name == "metrics-addon-agent-scrape-configs" {
+				scrapeConfigs = obj
+			}
+		case *unstructured.Unstructured:
+			if obj.GetKind() == "PrometheusAgent" {
+				prometheusAgent = obj
+			}
+		}
+	}
+	require.NotNil(t, prometheusAgent)
+	require.NotNil(t, scrapeConfigs)
+
+	var jobs []map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(scrapeConfigs.StringData["scrape-configs.yaml"]), &jobs))
+	require.Len(t, jobs, 2)
+	require.Equal(t, "federate", jobs[0]["job_name"])
+	require.Equal(t, "federate-user-workload", jobs[1]["job_name"])
+	require.Equal(t, map[string]interface{}{
+		"match[]": []interface{}{`{__name__=~"app_.*"}`},
+	}, jobs[1]["params"])
+
+	serviceMonitorSelector, _, err := unstructured.NestedMap(prometheusAgent.Object, "spec", "serviceMonitorSelector")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"matchLabels": map[string]interface{}{"observability.example.com/scrape": "true"},
+	}, serviceMonitorSelector)
+	namespaceSelector, found, err := unstructured.NestedMap(prometheusAgent.Object, "spec", "serviceMonitorNamespaceSelector")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Empty(t, namespaceSelector)
+	_, found, err = unstructured.NestedMap(prometheusAgent.Object, "spec", "podMonitorSelector")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	secrets, _, err := unstructured.NestedStringSlice(prometheusAgent.Object, "spec", "secrets")
+	require.NoError(t, err)
+	require.Contains(t, secrets, "metrics-remote-write-regional")
+
+	remoteWrite, _, err := unstructured.NestedSlice(prometheusAgent.Object, "spec", "remoteWrite")
+	require.NoError(t, err)
+	require.Len(t, remoteWrite, 2)
+
+	hub := remoteWrite[0].(map[string]interface{})
+	require.Equal(t, "https://observatorium.example.com/api/metrics/v1/default/api/v1/receive", hub["url"])
+	require.Equal(t, map[string]interface{}{
+		"caFile":   "/etc/prometheus/secrets/observability-managed-cluster-certs/ca.crt",
+		"certFile": "/etc/prometheus/secrets/observability-controller-open-cluster-management.io-observability-signer-client-cert/tls.crt",
+		"keyFile":  "/etc/prometheus/secrets/observability-controller-open-cluster-management.io-observability-signer-client-cert/tls.key",
+	}, hub["tlsConfig"])
+
+	regional := remoteWrite[1].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{
+		"username": map[string]interface{}{"name": "metrics-remote-write-regional", "key": "username"},
+		"password": map[string]interface{}{"name": "metrics-remote-write-regional", "key": "password"},
+	}, regional["basicAuth"])
+	require.Equal(t, map[string]interface{}{"maxShards": int64(20)}, regional["queueConfig"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"action": "labeldrop", "regex": "pod_template_hash"},
+	}, regional["writeRelabelConfigs"])
+}
+
+func Test_UsePrometheusAgent(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		claims  []clusterv1.ManagedClusterClaim
+		value   string
+		enabled bool
+		wantErr bool
+	}{
+		{
+			name: "Default",
+		},
+		{
+			name:    "ClusterClaim",
+			claims:  []clusterv1.ManagedClusterClaim{{Name: "prometheusagents.monitoring.coreos.com", Value: "true"}},
+			enabled: true,
+		},
+		{
+			name:   "VariableTakesPrecedence",
+			claims: []clusterv1.ManagedClusterClaim{{Name: "prometheusagents.monitoring.coreos.com", Value: "true"}},
+			value:  "false",
+		},
+		{
+			name:    "Variable",
+			value:   "true",
+			enabled: true,
+		},
+		{
+			name:    "InvalidVariable",
+			value:   "sometimes",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := addontesting.NewManagedCluster("cluster1")
+			cluster.Status.ClusterClaims = tc.claims
+			adoc := &addonapiv1alpha1.AddOnDeploymentConfig{}
+			if tc.value != "" {
+				adoc.Spec.CustomizedVariables = []addonapiv1alpha1.CustomizedVariable{
+					{Name: "metricsPrometheusAgent", Value: tc.value},
+				}
+			}
+
+			enabled, err := usePrometheusAgent(cluster, adoc)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.enabled, enabled)
+		})
+	}
+}
+
+func Test_GetValuesFunc_MonitorsRequirePrometheusAgent(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster1")
+	mca := addontesting.NewAddon("test", "cluster1")
+	mca.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-monitors",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-monitors",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"podMonitorSelector": "matchLabels: {team: a}",
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(config).Build()
+
+	_, err := GetValuesFunc(k8s, cluster, mca, nil)
+	require.Error(t, err)
+}
+
+func Test_GetValuesFunc_ReplicasRequirePrometheusAgent(t *testing.T) {
+	cluster := addontesting.NewManagedCluster("cluster1")
+	mca := addontesting.NewAddon("test", "cluster1")
+	mca.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-agent",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-agent",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"agentReplicas": "2",
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(config).Build()
+
+	_, err := GetValuesFunc(k8s, cluster, mca, nil)
+	require.ErrorContains(t, err, "replicas")
+}
+
+func Test_GenerateManagedClusterResources_PersistentStorage(t *testing.T) {
+	managedCluster := addontesting.NewManagedCluster("cluster1")
+	managedClusterAddOn := addontesting.NewAddon("test", "cluster1")
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	adoc := &addonapiv1alpha1.AddOnDeploymentConfig{
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsStorageSize", Value: "20Gi"},
+				{Name: "metricsStorageClass", Value: "gp3-csi"},
+			},
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route).Build()
+	getValues := func(cluster *clusterv1.ManagedCluster, mca *addonapiv1alpha1.ManagedClusterAddOn) (addonfactory.Values, error) {
+		values, err := GetValuesFunc(k8s, cluster, mca, adoc)
+		if err != nil {
+			return nil, err
+		}
+		return addonfactory.JsonStructToValues(values)
+	}
+
+	metricsAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, "manifests/charts/mcoa/charts/metrics").
+		WithGetValuesFuncs(getValues).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(scheme.Scheme).
+		BuildHelmAgentAddon()
+	require.NoError(t, err)
+
+	objects, err := metricsAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+
+	var statefulSet *v1.StatefulSet
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *v1.Deployment:
+			require.Fail(t, "unexpected deployment", obj.Name)
+		case *v1.StatefulSet:
+			statefulSet = obj
+		}
+	}
+	require.NotNil(t, statefulSet)
+	require.Equal(t, "metrics-addon-agent", statefulSet.Name)
+	require.Len(t, statefulSet.Spec.VolumeClaimTemplates, 1)
+
+	claim := statefulSet.Spec.VolumeClaimTemplates[0]
+	require.Equal(t, "prometheus-storage-volume", claim.Name)
+	require.Equal(t, "gp3-csi", *claim.Spec.StorageClassName)
+	require.Equal(t, resource.MustParse("20Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage])
+	for _, volume := range statefulSet.Spec.Template.Spec.Volumes {
+		require.NotEqual(t, "prometheus-storage-volume", volume.Name)
+	}
+}
+
+func Test_GetStorageValues(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		vars    []addonapiv1alpha1.CustomizedVariable
+		storage StorageValues
+		wantErr bool
+	}{
+		{
+			name: "NotConfigured",
+		},
+		{
+			name:    "Size",
+			vars:    []addonapiv1alpha1.CustomizedVariable{{Name: "metricsStorageSize", Value: "10Gi"}},
+			storage: StorageValues{Size: "10Gi"},
+		},
+		{
+			name:    "InvalidSize",
+			vars:    []addonapiv1alpha1.CustomizedVariable{{Name: "metricsStorageSize", Value: "lots"}},
+			wantErr: true,
+		},
+		{
+			name:    "ClassWithoutSize",
+			vars:    []addonapiv1alpha1.CustomizedVariable{{Name: "metricsStorageClass", Value: "gp3-csi"}},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			adoc := &addonapiv1alpha1.AddOnDeploymentConfig{
+				Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+					CustomizedVariables: tc.vars,
+				},
+			}
+			storage, err := getStorageValues(adoc)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.storage, storage)
+		})
+	}
+}
+
+func Test_GetAgentValues(t *testing.T) {
+	hubKey := client.ObjectKey{Name: "metrics-agent", Namespace: "open-cluster-management"}
+
+	for _, tc := range []struct {
+		name    string
+		data    map[string]string
+		want    AgentValues
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			want: AgentValues{
+				Replicas:  1,
+				LogLevel:  "info",
+				Resources: defaultAgentResources(),
+			},
+		},
+		{
+			name: "Configured",
+			data: map[string]string{
+				"agentReplicas":          "2",
+				"agentLogLevel":          "warn",
+				"agentPriorityClassName": "system-cluster-critical",
+				"agentResources":         "requests:\n  memory: 2Gi\nlimits:\n  memory: 4Gi\n",
+			},
+			want: AgentValues{
+				Replicas:          2,
+				LogLevel:          "warn",
+				PriorityClassName: "system-cluster-critical",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("2Gi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("1"),
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					},
+				},
+			},
+		},
+		{
+			name:    "InvalidReplicas",
+			data:    map[string]string{"agentReplicas": "0"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidLogLevel",
+			data:    map[string]string{"agentLogLevel": "verbose"},
+			wantErr: true,
+		},
+		{
+			name:    "RequestExceedsLimit",
+			data:    map[string]string{"agentResources": "requests:\n  cpu: 2\n"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := getAgentValues([]configData{{key: hubKey, data: tc.data}})
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, values)
+		})
+	}
+}
+
+func Test_GetDestinationEndpoint(t *testing.T) {
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+			To:   routev1.RouteTargetReference{Kind: "Service", Name: "observatorium-api"},
+			Port: &routev1.RoutePort{TargetPort: intstr.FromString("public")},
+			TLS:  &routev1.TLSConfig{Termination: routev1.TLSTerminationPassthrough},
+		},
+	}
+	routeService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "internal", Port: 8081},
+				{Name: "public", Port: 8080},
+			},
+		},
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "receiver",
+			Namespace: "thanos",
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: "receive.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: "receiver",
+											Port: networkingv1.ServiceBackendPort{Number: 19291},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	lbService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "receiver-lb",
+			Namespace: "thanos",
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Port: 19291}},
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}},
+			},
+		},
+	}
+	clusterIPService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "receiver",
+			Namespace: "thanos",
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{{Port: 19291}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		vars     []addonapiv1alpha1.CustomizedVariable
+		hub      bool
+		endpoint string
+		wantErr  bool
+	}{
+		{
+			name:     "DefaultRoute",
+			endpoint: "https://observatorium.example.com/api/metrics/v1/team-a/api/v1/receive",
+		},
+		{
+			name: "Explicit",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsDestinationEndpoint", Value: "https://metrics.example.com/receive"},
+			},
+			endpoint: "https://metrics.example.com/receive",
+		},
+		{
+			name: "ExplicitWithTenant",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsDestinationEndpoint", Value: "https://metrics.example.com/{tenant}/receive"},
+			},
+			endpoint: "https://metrics.example.com/team-a/receive",
+		},
+		{
+			name: "Ingress",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsEndpointRef", Value: "Ingress/thanos/receiver"},
+				{Name: "metricsEndpointPath", Value: "/api/v1/receive"},
+			},
+			endpoint: "https://receive.example.com/api/v1/receive",
+		},
+		{
+			name: "LoadBalancerService",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsEndpointRef", Value: "service/thanos/receiver-lb"},
+				{Name: "metricsEndpointPath", Value: "/{tenant}/api/v1/receive"},
+			},
+			endpoint: "https://10.0.0.1:19291/team-a/api/v1/receive",
+		},
+		{
+			name: "ClusterIPService",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsEndpointRef", Value: "service/thanos/receiver"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "MissingRoute",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsEndpointRef", Value: "route/thanos/receiver"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "InvalidRef",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsEndpointRef", Value: "thanos/receiver"},
+			},
+			wantErr: true,
+		},
+		{
+			name:     "HubRoute",
+			hub:      true,
+			endpoint: "https://observatorium-api.open-cluster-management-observability.svc:8080/api/metrics/v1/team-a/api/v1/receive",
+		},
+		{
+			name: "HubIngress",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsEndpointRef", Value: "ingress/thanos/receiver"},
+				{Name: "metricsEndpointPath", Value: "/api/v1/receive"},
+			},
+			hub:      true,
+			endpoint: "https://receiver.thanos.svc:19291/api/v1/receive",
+		},
+		{
+			name: "HubClusterIPService",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsEndpointRef", Value: "service/thanos/receiver"},
+				{Name: "metricsEndpointPath", Value: "/api/v1/receive"},
+			},
+			hub:      true,
+			endpoint: "https://receiver.thanos.svc:19291/api/v1/receive",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route, routeService, ingress, lbService, clusterIPService).Build()
+			adoc := &addonapiv1alpha1.AddOnDeploymentConfig{
+				Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+					CustomizedVariables: tc.vars,
+				},
+			}
+			cluster := addontesting.NewManagedCluster("cluster1")
+			if tc.hub {
+				cluster = addontesting.NewManagedCluster("local-cluster")
+			}
+
+			endpoint, err := getDestinationEndpoint(k8s, cluster, adoc, "team-a")
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.endpoint, endpoint)
+		})
+	}
+}
+
+func Test_GenerateManagedClusterResources_AlertForwarding(t *testing.T) {
+	managedCluster := addontesting.NewManagedCluster("cluster1")
+	managedClusterAddOn := addontesting.NewAddon("test", "cluster1")
+	managedClusterAddOn.Spec.Configs = []addonapiv1alpha1.AddOnConfig{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Resource: addon.ConfigMapResource,
+			},
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{
+				Name:      "metrics-alerts",
+				Namespace: "open-cluster-management",
+			},
+		},
+	}
+
+	observatorium := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	alertmanager := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "alertmanager",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "alertmanager.example.com",
+		},
+	}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-alerts",
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				addon.SignalLabelKey: addon.Metrics.String(),
+			},
+		},
+		Data: map[string]string{
+			"authentication": "alertmanager: StaticAuthentication\n",
+			"clusterMonitoringConfig": `enableUserWorkload: true
+prometheusK8s:
+  retention: 1d
+  nodeSelector:
+    node-role.kubernetes.io/infra: ""
+  additionalAlertmanagerConfigs:
+  - apiVersion: v2
+    scheme: http
+    staticConfigs:
+    - alertmanager.local:9093
+alertmanagerMain:
+  enabled: false
+`,
+		},
+	}
+	staticSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "metrics-static-authentication",
+			Namespace: "open-cluster-management",
+		},
+		Data: map[string][]byte{
+			"ca-bundle.crt": []byte("hub-ca"),
+			"token":         []byte("secret-token"),
+		},
+	}
+	adoc := &addonapiv1alpha1.AddOnDeploymentConfig{
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsAlertmanagerRef", Value: "route/open-cluster-management-observability/alertmanager"},
+			},
+		},
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(observatorium, alertmanager, config, staticSecret).Build()
+	getValues := func(cluster *clusterv1.ManagedCluster, mca *addonapiv1alpha1.ManagedClusterAddOn) (addonfactory.Values, error) {
+		values, err := GetValuesFunc(k8s, cluster, mca, adoc)
+		if err != nil {
+			return nil, err
+		}
+		return addonfactory.JsonStructToValues(values)
+	}
+
+	metricsAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, "manifests/charts/mcoa/charts/metrics").
+		WithGetValuesFuncs(getValues).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(scheme.Scheme).
+		BuildHelmAgentAddon()
+	require.NoError(t, err)
+
+	objects, err := metricsAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+	require.NoError(t, err)
+
+	var (
+		monitoringConfig *corev1.ConfigMap
+		authSecret       *corev1.Secret
+	)
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *corev1.ConfigMap:
+			if obj.Name == "cluster-monitoring-config" {
+				monitoringConfig = obj
+			}
+		case *corev1.Secret:
+			if obj.Name == "metrics-alertmanager-auth" {
+				authSecret = obj
+			}
+		}
+	}
+	require.NotNil(t, monitoringConfig)
+	require.Equal(t, "openshift-monitoring", monitoringConfig.Namespace)
+	require.Contains(t, monitoringConfig.Annotations, addonapiv1alpha1.DeletionOrphanAnnotationKey)
+	require.NotNil(t, authSecret)
+	require.Equal(t, "openshift-monitoring", authSecret.Namespace)
+	require.Equal(t, staticSecret.Data, authSecret.Data)
+
+	clusterMonitoringConfig := struct {
+		EnableUserWorkload bool `json:"enableUserWorkload"`
+		PrometheusK8s      struct {
+			Retention                     string               `json:"retention"`
+			NodeSelector                  map[string]string    `json:"nodeSelector"`
+			ExternalLabels                map[string]string    `json:"externalLabels"`
+			AdditionalAlertmanagerConfigs []alertmanagerConfig `json:"additionalAlertmanagerConfigs"`
+		} `json:"prometheusK8s"`
+		AlertmanagerMain struct {
+			Enabled *bool `json:"enabled"`
+		} `json:"alertmanagerMain"`
+	}{}
+	err = yaml.Unmarshal([]byte(monitoringConfig.Data["config.yaml"]), &clusterMonitoringConfig)
+	require.NoError(t, err)
+	// The settings of the cluster monitoring survive the alert forwarding
+	require.True(t, clusterMonitoringConfig.EnableUserWorkload)
+	require.Equal(t, "1d", clusterMonitoringConfig.PrometheusK8s.Retention)
+	require.Equal(t, map[string]string{"node-role.kubernetes.io/infra": ""}, clusterMonitoringConfig.PrometheusK8s.NodeSelector)
+	require.NotNil(t, clusterMonitoringConfig.AlertmanagerMain.Enabled)
+	require.False(t, *clusterMonitoringConfig.AlertmanagerMain.Enabled)
+	require.Equal(t, "cluster1", clusterMonitoringConfig.PrometheusK8s.ExternalLabels["cluster"])
+
+	secretRef := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "metrics-alertmanager-auth"},
+			Key:                  key,
+		}
+	}
+	require.Equal(t, []alertmanagerConfig{
+		{
+			APIVersion:    "v2",
+			Scheme:        "http",
+			StaticConfigs: []string{"alertmanager.local:9093"},
+		},
+		{
+			APIVersion:    "v2",
+			Scheme:        "https",
+			StaticConfigs: []string{"alertmanager.example.com"},
+			TLSConfig:     &alertmanagerTLSConfig{CA: secretRef("ca-bundle.crt")},
+			BearerToken:   secretRef("token"),
+		},
+	}, clusterMonitoringConfig.PrometheusK8s.AdditionalAlertmanagerConfigs)
+}
+
+func Test_GetAlertForwardingValues_ClusterMonitoringConfig(t *testing.T) {
+	alertmanager := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "alertmanager",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "alertmanager.example.com",
+		},
+	}
+	forwarding := &addonapiv1alpha1.AddOnDeploymentConfig{
+		Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "metricsAlertmanagerRef", Value: "route/open-cluster-management-observability/alertmanager"},
+			},
+		},
+	}
+	monitoringConfig := []configData{
+		{
+			key:  client.ObjectKey{Name: "metrics-alerts", Namespace: "open-cluster-management"},
+			data: map[string]string{"clusterMonitoringConfig": "enableUserWorkload: true\n"},
+		},
+	}
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(alertmanager).Build()
+
+	// The configuration of the cluster monitoring isn't taken over without
+	// being handed over
+	_, err := getAlertForwardingValues(k8s, nil, "cluster1", forwarding, nil, nil)
+	require.Error(t, err)
+
+	values, err := getAlertForwardingValues(k8s, nil, "cluster1", nil, nil, nil)
+	require.NoError(t, err)
+	require.False(t, values.Enabled)
+	require.False(t, values.ManageConfig)
+
+	// The configuration handed over stays managed when alerts aren't forwarded
+	values, err = getAlertForwardingValues(k8s, nil, "cluster1", nil, monitoringConfig, nil)
+	require.NoError(t, err)
+	require.False(t, values.Enabled)
+	require.True(t, values.ManageConfig)
+	require.Equal(t, map[string]interface{}{"enableUserWorkload": true}, values.ClusterMonitoringConfig)
+
+	values, err = getAlertForwardingValues(k8s, nil, "cluster1", forwarding, monitoringConfig, map[string]string{"cluster": "cluster1"})
+	require.NoError(t, err)
+	require.True(t, values.Enabled)
+	require.True(t, values.ManageConfig)
+	require.Equal(t, true, values.ClusterMonitoringConfig["enableUserWorkload"])
+	require.Contains(t, values.ClusterMonitoringConfig, "prometheusK8s")
+}
+
+func Test_GenerateManagedClusterResources_Namespace(t *testing.T) {
+	managedCluster := addontesting.NewManagedCluster("cluster1")
+	managedClusterAddOn := addontesting.NewAddon("test", "cluster1")
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observatorium-api",
+			Namespace: "open-cluster-management-observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "observatorium.example.com",
+		},
+	}
+	k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route).Build()
+
+	for _, tc := range []struct {
+		name      string
+		namespace string
+		created   bool
+		wantErr   bool
+	}{
+		{
+			name:      "Custom",
+			namespace: "team-metrics",
+			created:   true,
+		},
+		{
+			name:      "AddonInstallNamespace",
+			namespace: "open-cluster-management-agent-addon",
+		},
+		{
+			name:      "Invalid",
+			namespace: "Team_Metrics",
+			wantErr:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			adoc := &addonapiv1alpha1.AddOnDeploymentConfig{
+				Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+					CustomizedVariables: []addonapiv1alpha1.CustomizedVariable{
+						{Name: "metricsNamespace", Value: tc.namespace},
+					},
+				},
+			}
+			getValues := func(cluster *clusterv1.ManagedCluster, mca *addonapiv1alpha1.ManagedClusterAddOn) (addonfactory.Values, error) {
+				values, err := GetValuesFunc(k8s, cluster, mca, adoc)
+				if err != nil {
+					return nil, err
+				}
+				return addonfactory.JsonStructToValues(values)
+			}
+
+			metricsAgentAddon, err := addonfactory.NewAgentAddonFactory(addon.Name, addon.FS, "manifests/charts/mcoa/charts/metrics").
+				WithGetValuesFuncs(getValues).
+				WithAgentRegistrationOption(&agent.RegistrationOption{}).
+				WithScheme(scheme.Scheme).
+				BuildHelmAgentAddon()
+			require.NoError(t, err)
+
+			objects, err := metricsAgentAddon.Manifests(managedCluster, managedClusterAddOn)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			created := false
+			for _, obj := range objects {
+				accessor, err := meta.Accessor(obj)
+				require.NoError(t, err)
+				switch obj.(type) {
+				case *corev1.Namespace:
+					require.Equal(t, tc.namespace, accessor.GetName())
+					created = true
+				case *v1.Deployment, *corev1.ConfigMap, *corev1.Secret, *corev1.ServiceAccount:
+					require.Equal(t, tc.namespace, accessor.GetNamespace(), accessor.GetName())
+				}
+			}
+			require.Equal(t, tc.created, created)
+		})
+	}
+}
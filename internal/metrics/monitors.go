@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"github.com/ViaQ/logerr/v2/kverrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// serviceMonitorSelectorKey holds in a metrics ConfigMap the label
+	// selector of the ServiceMonitors scraped by the PrometheusAgent
+	serviceMonitorSelectorKey = "serviceMonitorSelector"
+	// podMonitorSelectorKey holds in a metrics ConfigMap the label selector
+	// of the PodMonitors scraped by the PrometheusAgent
+	podMonitorSelectorKey = "podMonitorSelector"
+	// monitorNamespaceSelectorKey holds in a metrics ConfigMap the label
+	// selector of the namespaces where ServiceMonitors and PodMonitors are
+	// selected. An empty selector selects all namespaces.
+	monitorNamespaceSelectorKey = "monitorNamespaceSelector"
+)
+
+type MonitorsValues struct {
+	ServiceMonitorSelector *metav1.LabelSelector `json:"serviceMonitorSelector,omitempty"`
+	PodMonitorSelector     *metav1.LabelSelector `json:"podMonitorSelector,omitempty"`
+	NamespaceSelector      *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// getMonitorsValues returns the selectors of the ServiceMonitors and
+// PodMonitors scraped by the PrometheusAgent and whether they were set in
+// the metrics configuration data. By default only the ServiceMonitors of the
+// addon in the namespace of the agent are selected.
+func getMonitorsValues(configs []configData) (MonitorsValues, bool, error) {
+	values := MonitorsValues{
+		ServiceMonitorSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app.kubernetes.io/part-of": "multicluster-observability-addon",
+			},
+		},
+	}
+
+	configured := false
+	for _, config := range configs {
+		for _, selector := range []struct {
+			key   string
+			value **metav1.LabelSelector
+		}{
+			{key: serviceMonitorSelectorKey, value: &values.ServiceMonitorSelector},
+			{key: podMonitorSelectorKey, value: &values.PodMonitorSelector},
+			{key: monitorNamespaceSelectorKey, value: &values.NamespaceSelector},
+		} {
+			key := selector.key
+			raw, ok := config.data[key]
+			if !ok {
+				continue
+			}
+			parsed, err := parseLabelSelector(raw)
+			if err != nil {
+				return values, false, kverrors.Wrap(err, "invalid label selector", "key", key, "name", config.key.Name, "namespace", config.key.Namespace)
+			}
+			*selector.value = parsed
+			configured = true
+		}
+	}
+
+	return values, configured, nil
+}
+
+func parseLabelSelector(raw string) (*metav1.LabelSelector, error) {
+	selector := &metav1.LabelSelector{}
+	if err := yaml.Unmarshal([]byte(raw), selector); err != nil {
+		return nil, err
+	}
+	if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+		return nil, err
+	}
+	return selector, nil
+}
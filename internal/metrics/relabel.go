@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// metricRelabelConfigsKey holds in a metrics ConfigMap a YAML list of
+	// relabel configs applied to the federated series before they are stored
+	metricRelabelConfigsKey = "metricRelabelConfigs"
+	// writeRelabelConfigsKey holds in a metrics ConfigMap a YAML list of
+	// relabel configs applied to the series sent to every remote write
+	// destination
+	writeRelabelConfigsKey = "writeRelabelConfigs"
+)
+
+// relabelActions are the relabel actions supported by the prometheus agent,
+// mapped to whether they require a target label.
+var relabelActions = map[string]bool{
+	"replace":   true,
+	"keep":      false,
+	"drop":      false,
+	"keepequal": true,
+	"dropequal": true,
+	"hashmod":   true,
+	"labelmap":  false,
+	"labeldrop": false,
+	"labelkeep": false,
+	"lowercase": true,
+	"uppercase": true,
+}
+
+// RelabelConfig maps to a Prometheus relabel_config, empty values keep the
+// Prometheus defaults.
+type RelabelConfig struct {
+	SourceLabels []string `json:"sourceLabels"`
+	Separator    string   `json:"separator"`
+	Regex        string   `json:"regex"`
+	Modulus      uint64   `json:"modulus"`
+	TargetLabel  string   `json:"targetLabel"`
+	Replacement  string   `json:"replacement"`
+	Action       string   `json:"action"`
+}
+
+// parseRelabelConfigs decodes and validates the YAML list of relabel configs
+// stored under key. Unknown fields, e.g. the snake case ones of the
+// Prometheus configuration, are rejected.
+func parseRelabelConfigs(key, raw string) ([]RelabelConfig, error) {
+	var configs []RelabelConfig
+	if err := yaml.UnmarshalStrict([]byte(raw), &configs); err != nil {
+		return nil, kverrors.Wrap(err, "failed to parse relabel configs", "key", key)
+	}
+	if err := validateRelabelConfigs(configs); err != nil {
+		return nil, kverrors.Wrap(err, "invalid relabel configs", "key", key)
+	}
+	return configs, nil
+}
+
+func validateRelabelConfigs(configs []RelabelConfig) error {
+	for i, config := range configs {
+		action := config.Action
+		if action == "" {
+			action = "replace"
+		}
+
+		needsTarget, ok := relabelActions[action]
+		if !ok {
+			return kverrors.New("unknown relabel action", "index", i, "action", config.Action)
+		}
+		if needsTarget && config.TargetLabel == "" {
+			return kverrors.New("relabel action requires a target label", "index", i, "action", action)
+		}
+		if action == "hashmod" && config.Modulus == 0 {
+			return kverrors.New("relabel action requires a modulus", "index", i, "action", action)
+		}
+		if config.Regex != "" {
+			if _, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", config.Regex)); err != nil {
+				return kverrors.Wrap(err, "invalid relabel regex", "index", i, "regex", config.Regex)
+			}
+		}
+	}
+	return nil
+}
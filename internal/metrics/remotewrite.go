@@ -0,0 +1,330 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// remoteWriteKey holds in a metrics ConfigMap a YAML list of additional
+	// remote write destinations
+	remoteWriteKey = "remoteWrite"
+
+	// hubRemoteWriteName is the name of the destination on the hub
+	hubRemoteWriteName = "hub"
+	// remoteWriteSecretPrefix prefixes the name of the secrets holding the
+	// credentials of the remote write destinations on the spoke cluster
+	remoteWriteSecretPrefix = "metrics-remote-write-"
+	// secretsMountPath is the directory where every secret used by the
+	// prometheus agent is mounted, following the Prometheus Operator
+	// convention of one subdirectory per secret
+	secretsMountPath = "/etc/prometheus/secrets"
+
+	// hubCASecretName and hubCertSecretName are the secrets provided by the
+	// multicluster observability operator to authenticate with the hub
+	hubCASecretName   = "observability-managed-cluster-certs"
+	hubCertSecretName = "observability-controller-open-cluster-management.io-observability-signer-client-cert"
+
+	secretKeyCA       = "ca-bundle.crt"
+	secretKeyCert     = "tls.crt"
+	secretKeyKey      = "tls.key"
+	secretKeyUsername = "username"
+	secretKeyPassword = "password"
+	secretKeyToken    = "token"
+	// secretKeyIssuerCA is set by cert-manager with the CA of the issuer
+	secretKeyIssuerCA = "ca.crt"
+)
+
+// RemoteWriteConfig is an additional remote write destination of the metrics
+// agent.
+type RemoteWriteConfig struct {
+	// Name identifies the destination, it must be a DNS label.
+	Name string `json:"name"`
+	// URL of the remote write endpoint.
+	URL string `json:"url"`
+	// SecretName references a Secret in the namespace of the managed cluster
+	// holding the credentials of the destination. The keys ca-bundle.crt (or
+	// ca.crt), tls.crt and tls.key configure TLS, username and password configure
+	// basic authentication and token configures a bearer token.
+	SecretName string `json:"secretName,omitempty"`
+	// QueueConfig tunes the queue of samples sent to the destination.
+	QueueConfig *QueueConfig `json:"queueConfig,omitempty"`
+	// WriteRelabelConfigs are applied to the samples before they are sent
+	// to the destination.
+	WriteRelabelConfigs []RelabelConfig `json:"writeRelabelConfigs,omitempty"`
+}
+
+// QueueConfig maps to the Prometheus remote write queue_config, zero values
+// keep the Prometheus defaults.
+type QueueConfig struct {
+	Capacity          int    `json:"capacity"`
+	MinShards         int    `json:"minShards"`
+	MaxShards         int    `json:"maxShards"`
+	MaxSamplesPerSend int    `json:"maxSamplesPerSend"`
+	BatchSendDeadline string `json:"batchSendDeadline"`
+	MinBackoff        string `json:"minBackoff"`
+	MaxBackoff        string `json:"maxBackoff"`
+}
+
+type RemoteWriteValue struct {
+	Name                string                     `json:"name"`
+	URL                 string                     `json:"url"`
+	SecretName          string                     `json:"secretName"`
+	TLSConfig           *RemoteWriteTLSValue       `json:"tlsConfig"`
+	BasicAuth           *RemoteWriteBasicAuthValue `json:"basicAuth"`
+	BearerTokenFile     string                     `json:"bearerTokenFile"`
+	QueueConfig         *QueueConfig               `json:"queueConfig"`
+	WriteRelabelConfigs []RelabelConfig            `json:"writeRelabelConfigs"`
+}
+
+type RemoteWriteTLSValue struct {
+	CAFile   string `json:"caFile"`
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+type RemoteWriteBasicAuthValue struct {
+	UsernameFile string `json:"usernameFile"`
+	PasswordFile string `json:"passwordFile"`
+}
+
+type SecretValue struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+// buildHubRemoteWrite returns the destination on the hub. Its credentials are
+// set by the tenant secret or the authentication of the destination.
+func buildHubRemoteWrite(endpoint string) RemoteWriteValue {
+	return RemoteWriteValue{
+		Name:                hubRemoteWriteName,
+		URL:                 endpoint,
+		WriteRelabelConfigs: []RelabelConfig{},
+	}
+}
+
+// withMCOCredentials authenticates the destination with the certificates
+// provided by the multicluster observability operator.
+func withMCOCredentials(value *RemoteWriteValue) {
+	value.TLSConfig = &RemoteWriteTLSValue{
+		CAFile:   secretFile(hubCASecretName, "ca.crt"),
+		CertFile: secretFile(hubCertSecretName, secretKeyCert),
+		KeyFile:  secretFile(hubCertSecretName, secretKeyKey),
+	}
+}
+
+// getRemoteWriteValues returns the remote write destinations, starting with
+// the one on the hub, from the metrics configuration data and the secrets
+// holding their credentials on the spoke cluster. A destination named hub
+// without URL and secret tunes the queue and relabeling of the hub one.
+func getRemoteWriteValues(k8sClient client.Client, clusterNamespace string, hub RemoteWriteValue, configs []configData) ([]RemoteWriteValue, []SecretValue, error) {
+	var (
+		values  = []RemoteWriteValue{hub}
+		secrets = []SecretValue{}
+		names   = map[string]bool{}
+		global  = []RelabelConfig{}
+	)
+
+	for _, config := range configs {
+		raw, ok := config.data[writeRelabelConfigsKey]
+		if !ok {
+			continue
+		}
+		relabelConfigs, err := parseRelabelConfigs(writeRelabelConfigsKey, raw)
+		if err != nil {
+			return nil, nil, kverrors.Wrap(err, "invalid remote write configuration", "name", config.key.Name, "namespace", config.key.Namespace)
+		}
+		global = append(global, relabelConfigs...)
+	}
+
+	for _, config := range configs {
+		raw, ok := config.data[remoteWriteKey]
+		if !ok {
+			continue
+		}
+
+		var destinations []RemoteWriteConfig
+		// Unknown fields are rejected, a misspelled one would be ignored
+		if err := yaml.UnmarshalStrict([]byte(raw), &destinations); err != nil {
+			return nil, nil, kverrors.Wrap(err, "failed to parse remote write destinations", "name", config.key.Name, "namespace", config.key.Namespace)
+		}
+
+		for _, destination := range destinations {
+			if names[destination.Name] {
+				return nil, nil, kverrors.New("duplicate remote write destination", "destination", destination.Name)
+			}
+			names[destination.Name] = true
+
+			if destination.Name == hubRemoteWriteName {
+				if destination.URL != "" || destination.SecretName != "" {
+					return nil, nil, kverrors.New("the hub remote write destination only supports queue and relabel configs", "name", config.key.Name, "namespace", config.key.Namespace)
+				}
+				if err := validateDestinationTuning(destination); err != nil {
+					return nil, nil, kverrors.Wrap(err, "invalid remote write destination", "name", config.key.Name, "namespace", config.key.Namespace)
+				}
+				values[0].QueueConfig = destination.QueueConfig
+				if destination.WriteRelabelConfigs != nil {
+					values[0].WriteRelabelConfigs = destination.WriteRelabelConfigs
+				}
+				continue
+			}
+
+			if err := validateRemoteWrite(destination); err != nil {
+				return nil, nil, kverrors.Wrap(err, "invalid remote write destination", "name", config.key.Name, "namespace", config.key.Namespace)
+			}
+
+			value, secret, err := buildRemoteWrite(k8sClient, clusterNamespace, destination)
+			if err != nil {
+				return nil, nil, err
+			}
+			values = append(values, value)
+			if secret != nil {
+				secrets = append(secrets, *secret)
+			}
+		}
+	}
+
+	// The relabel configs shared by every destination run first
+	for i := range values {
+		values[i].WriteRelabelConfigs = append(append([]RelabelConfig{}, global...), values[i].WriteRelabelConfigs...)
+	}
+
+	return values, secrets, nil
+}
+
+func validateRemoteWrite(destination RemoteWriteConfig) error {
+	if errs := validation.IsDNS1123Label(destination.Name); len(errs) > 0 {
+		return kverrors.New("invalid name", "destination", destination.Name, "reason", strings.Join(errs, ", "))
+	}
+	if destination.Name == alertmanagerTarget {
+		return kverrors.New("reserved name", "destination", destination.Name)
+	}
+	if destination.URL == "" {
+		return kverrors.New("missing url", "destination", destination.Name)
+	}
+
+	return validateDestinationTuning(destination)
+}
+
+func validateDestinationTuning(destination RemoteWriteConfig) error {
+	if err := validateRelabelConfigs(destination.WriteRelabelConfigs); err != nil {
+		return kverrors.Wrap(err, "invalid write relabel configs", "destination", destination.Name)
+	}
+
+	if queue := destination.QueueConfig; queue != nil {
+		for _, d := range []string{queue.BatchSendDeadline, queue.MinBackoff, queue.MaxBackoff} {
+			if d == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(d); err != nil {
+				return kverrors.Wrap(err, "invalid queue duration", "destination", destination.Name, "value", d)
+			}
+		}
+		if queue.Capacity < 0 || queue.MinShards < 0 || queue.MaxShards < 0 || queue.MaxSamplesPerSend < 0 {
+			return kverrors.New("queue settings must not be negative", "destination", destination.Name)
+		}
+	}
+
+	return nil
+}
+
+func buildRemoteWrite(k8sClient client.Client, clusterNamespace string, destination RemoteWriteConfig) (RemoteWriteValue, *SecretValue, error) {
+	value := RemoteWriteValue{
+		Name:                destination.Name,
+		URL:                 destination.URL,
+		QueueConfig:         destination.QueueConfig,
+		WriteRelabelConfigs: destination.WriteRelabelConfigs,
+	}
+	if value.WriteRelabelConfigs == nil {
+		value.WriteRelabelConfigs = []RelabelConfig{}
+	}
+
+	if destination.SecretName == "" {
+		return value, nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Name: destination.SecretName, Namespace: clusterNamespace}
+	if err := k8sClient.Get(context.Background(), key, secret, &client.GetOptions{}); err != nil {
+		return value, nil, kverrors.Wrap(err, "failed to get remote write secret", "destination", destination.Name, "name", key.Name, "namespace", key.Namespace)
+	}
+
+	secretValue, err := withRemoteWriteSecret(&value, secret)
+	if err != nil {
+		return value, nil, kverrors.Wrap(err, "invalid remote write secret", "destination", destination.Name, "name", key.Name)
+	}
+	return value, secretValue, nil
+}
+
+// withRemoteWriteSecret configures the authentication of the destination from
+// the keys of the secret and returns the secret to create on the spoke
+// cluster. TLS settings of the secret replace the ones already set on the
+// destination.
+func withRemoteWriteSecret(value *RemoteWriteValue, secret *corev1.Secret) (*SecretValue, error) {
+	secretName := fmt.Sprintf("%s%s", remoteWriteSecretPrefix, value.Name)
+	file := func(k string) string { return secretFile(secretName, k) }
+
+	_, hasCert := secret.Data[secretKeyCert]
+	_, hasKey := secret.Data[secretKeyKey]
+	if hasCert != hasKey {
+		return nil, kverrors.New("remote write secret must set both tls.crt and tls.key")
+	}
+	caKey := ""
+	for _, k := range []string{secretKeyCA, secretKeyIssuerCA} {
+		if _, ok := secret.Data[k]; ok {
+			caKey = k
+			break
+		}
+	}
+	if (caKey != "" || hasCert) && value.TLSConfig == nil {
+		value.TLSConfig = &RemoteWriteTLSValue{}
+	}
+	if caKey != "" {
+		value.TLSConfig.CAFile = file(caKey)
+	}
+	if hasCert {
+		value.TLSConfig.CertFile = file(secretKeyCert)
+		value.TLSConfig.KeyFile = file(secretKeyKey)
+	}
+
+	_, hasUsername := secret.Data[secretKeyUsername]
+	_, hasPassword := secret.Data[secretKeyPassword]
+	_, hasToken := secret.Data[secretKeyToken]
+	switch {
+	case hasUsername != hasPassword:
+		return nil, kverrors.New("remote write secret must set both username and password")
+	case hasUsername && hasToken:
+		return nil, kverrors.New("remote write secret must not set both basic authentication and a token")
+	case hasUsername:
+		value.BasicAuth = &RemoteWriteBasicAuthValue{
+			UsernameFile: file(secretKeyUsername),
+			PasswordFile: file(secretKeyPassword),
+		}
+	case hasToken:
+		value.BearerTokenFile = file(secretKeyToken)
+	}
+
+	b, err := json.Marshal(secret.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	value.SecretName = secretName
+	return &SecretValue{Name: value.SecretName, Data: string(b)}, nil
+}
+
+// secretFile returns the path of the key of a secret mounted in the
+// prometheus agent.
+func secretFile(secretName, key string) string {
+	return path.Join(secretsMountPath, secretName, key)
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/ViaQ/logerr/v2/kverrors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+)
+
+const (
+	// storageSizeValueKey sets the size of the persistent volume holding the
+	// WAL of the metrics agent. The WAL is kept in an emptyDir when unset.
+	storageSizeValueKey = "metricsStorageSize"
+	// storageClassValueKey sets the storage class of the persistent volume
+	// holding the WAL of the metrics agent
+	storageClassValueKey = "metricsStorageClass"
+)
+
+type StorageValues struct {
+	Size             string `json:"size"`
+	StorageClassName string `json:"storageClassName"`
+}
+
+// getStorageValues returns the persistent storage of the WAL of the metrics
+// agent configured in the AddOnDeploymentConfig.
+func getStorageValues(adoc *addonapiv1alpha1.AddOnDeploymentConfig) (StorageValues, error) {
+	values := StorageValues{}
+	if adoc == nil {
+		return values, nil
+	}
+
+	for _, customVar := range adoc.Spec.CustomizedVariables {
+		switch customVar.Name {
+		case storageSizeValueKey:
+			size, err := resource.ParseQuantity(customVar.Value)
+			if err != nil {
+				return values, kverrors.Wrap(err, "invalid customized variable", "name", storageSizeValueKey, "value", customVar.Value)
+			}
+			if size.Sign() <= 0 {
+				return values, kverrors.New("storage size must be positive", "name", storageSizeValueKey, "value", customVar.Value)
+			}
+			values.Size = size.String()
+		case storageClassValueKey:
+			values.StorageClassName = customVar.Value
+		}
+	}
+
+	if values.StorageClassName != "" && values.Size == "" {
+		return values, kverrors.New("storage class requires a storage size", "name", storageClassValueKey, "size", storageSizeValueKey)
+	}
+	return values, nil
+}
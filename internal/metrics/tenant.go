@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ViaQ/logerr/v2/kverrors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// tenantKey holds in a metrics ConfigMap the Observatorium tenant the
+	// metrics of the managed cluster are written to
+	tenantKey = "tenant"
+	// tenantLabelValueKey selects the label of the ManagedCluster holding the
+	// tenant, e.g. cluster.open-cluster-management.io/clusterset to use a
+	// tenant per ManagedClusterSet
+	tenantLabelValueKey = "metricsTenantLabel"
+	defaultTenant       = "default"
+
+	// tenantSecretNameFormat is formatted with the tenant to name the
+	// optional Secret, in the namespace of the managed cluster, holding the
+	// credentials of the tenant
+	tenantSecretNameFormat = "metrics-tenant-%s"
+)
+
+// getTenant returns the Observatorium tenant of the managed cluster. The
+// tenant set in the metrics ConfigMaps takes precedence over the one read
+// from the label of the ManagedCluster selected in the AddOnDeploymentConfig.
+func getTenant(cluster *clusterv1.ManagedCluster, adoc *addonapiv1alpha1.AddOnDeploymentConfig, configs []configData) (string, error) {
+	tenant := ""
+	for _, config := range configs {
+		if value, ok := config.data[tenantKey]; ok {
+			tenant = strings.TrimSpace(value)
+		}
+	}
+
+	if tenant == "" && cluster != nil && adoc != nil {
+		for _, customVar := range adoc.Spec.CustomizedVariables {
+			if customVar.Name == tenantLabelValueKey && customVar.Value != "" {
+				tenant = cluster.Labels[customVar.Value]
+			}
+		}
+	}
+
+	if tenant == "" {
+		return defaultTenant, nil
+	}
+	if errs := validation.IsDNS1123Label(tenant); len(errs) > 0 {
+		return "", kverrors.New("invalid metrics tenant", "tenant", tenant, "reason", strings.Join(errs, ", "))
+	}
+	return tenant, nil
+}
+
+// withTenantCredentials configures the hub destination with the credentials
+// of the tenant when its Secret exists in the namespace of the managed
+// cluster. Otherwise the certificates provided by the multicluster
+// observability operator are used.
+func withTenantCredentials(k8sClient client.Client, clusterNamespace, tenant string, hub *RemoteWriteValue) (*SecretValue, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Name: fmt.Sprintf(tenantSecretNameFormat, tenant), Namespace: clusterNamespace}
+	if err := k8sClient.Get(context.Background(), key, secret, &client.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, kverrors.Wrap(err, "failed to get tenant secret", "tenant", tenant, "name", key.Name, "namespace", key.Namespace)
+	}
+
+	secretValue, err := withRemoteWriteSecret(hub, secret)
+	if err != nil {
+		return nil, kverrors.Wrap(err, "invalid tenant secret", "tenant", tenant, "name", key.Name, "namespace", key.Namespace)
+	}
+	return secretValue, nil
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/ViaQ/logerr/v2/kverrors"
 	otelv1alpha1 "github.com/open-telemetry/opentelemetry-operator/apis/v1alpha1"
@@ -12,20 +13,45 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	AnnotationCAToInject           = "tracing.mcoa.openshift.io/ca"
 	opentelemetryCollectorResource = "opentelemetrycollectors"
+	opentelemetryOperatorPackage   = "opentelemetry-product"
+
+	// gatewayRefValueKey references, as kind/namespace/name, the Route,
+	// Ingress or LoadBalancer Service on the hub exposing a TempoStack gateway
+	// or any OTLP gateway
+	gatewayRefValueKey = "tracingGatewayRef"
+	// gatewayRouteValueKey references, as namespace/name, the Route on the
+	// hub exposing the gateway, a shorthand for a route gatewayRefValueKey
+	gatewayRouteValueKey = "tracingGatewayRoute"
 )
 
-func BuildOptions(k8s client.Client, mcAddon *addonapiv1alpha1.ManagedClusterAddOn, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (manifests.Options, error) {
+func BuildOptions(k8s client.Client, cluster *clusterv1.ManagedCluster, mcAddon *addonapiv1alpha1.ManagedClusterAddOn, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (manifests.Options, error) {
 	resources := manifests.Options{
 		AddOnDeploymentConfig: adoc,
 		ClusterName:           mcAddon.Namespace,
 	}
 
+	gatewayHost, err := discoverGateway(k8s, adoc)
+	if err != nil {
+		return resources, err
+	}
+	resources.GatewayHost = gatewayHost
+
+	// The hub may already run the OpenTelemetry operator for its own stack
+	if addon.IsHubCluster(cluster) {
+		runsOperator, err := addon.HubRunsOperator(k8s, opentelemetryOperatorPackage)
+		if err != nil {
+			return resources, err
+		}
+		resources.HubRunsOperator = runsOperator
+	}
+
 	klog.Info("Retrieving OpenTelemetry Collector template")
 	key := addon.GetObjectKey(mcAddon.Status.ConfigReferences, otelv1alpha1.GroupVersion.Group, opentelemetryCollectorResource)
 	otelCol := &otelv1alpha1.OpenTelemetryCollector{}
@@ -87,8 +113,11 @@ func BuildOptions(k8s client.Client, mcAddon *addonapiv1alpha1.ManagedClusterAdd
 	}
 
 	ctx := context.Background()
-	authConfig := manifests.AuthDefaultConfig
-	authConfig.MTLSConfig.CommonName = mcAddon.Namespace
+	namespace, err := manifests.BuildNamespace(resources)
+	if err != nil {
+		return resources, err
+	}
+	authConfig := manifests.BuildAuthConfig(mcAddon.Namespace, namespace)
 	if caSecret == nil {
 		klog.Warning("no CA was found")
 	} else if len(caSecret.Data) > 0 {
@@ -118,3 +147,42 @@ func BuildOptions(k8s client.Client, mcAddon *addonapiv1alpha1.ManagedClusterAdd
 
 	return resources, nil
 }
+
+// discoverGateway returns the host, with its port unless it is the HTTPS
+// one, of the Route, Ingress or Service referenced in the
+// AddOnDeploymentConfig that exposes the trace gateway on the hub. Unlike the
+// other signals, the hub cluster reaches the gateway through the same host.
+// An empty host is returned when no gateway is referenced.
+func discoverGateway(k8s client.Client, adoc *addonapiv1alpha1.AddOnDeploymentConfig) (string, error) {
+	if adoc == nil {
+		return "", nil
+	}
+
+	var ref, routeRef string
+	for _, keyvalue := range adoc.Spec.CustomizedVariables {
+		switch keyvalue.Name {
+		case gatewayRefValueKey:
+			ref = keyvalue.Value
+		case gatewayRouteValueKey:
+			routeRef = keyvalue.Value
+		}
+	}
+
+	valueKey := gatewayRefValueKey
+	if ref == "" && routeRef != "" {
+		namespace, name, ok := strings.Cut(routeRef, "/")
+		if !ok || namespace == "" || name == "" {
+			return "", kverrors.New("invalid gateway route reference, expected namespace/name", "name", gatewayRouteValueKey, "value", routeRef)
+		}
+		ref, valueKey = fmt.Sprintf("route/%s/%s", namespace, name), gatewayRouteValueKey
+	}
+	if ref == "" {
+		return "", nil
+	}
+
+	u, err := addon.DiscoverURL(k8s, valueKey, ref, false)
+	if err != nil {
+		return "", err
+	}
+	return u.Host, nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"testing"
+
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = routev1.AddToScheme(scheme.Scheme)
+
+func Test_DiscoverGateway(t *testing.T) {
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tempo-hub-gateway",
+			Namespace: "observability",
+		},
+		Spec: routev1.RouteSpec{
+			Host: "tempo-hub-gateway.apps.example.com",
+			To:   routev1.RouteTargetReference{Kind: "Service", Name: "tempo-hub-gateway"},
+		},
+	}
+	lbService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "otlp-gateway",
+			Namespace: "observability",
+		},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Port: 4317}},
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		vars    []addonapiv1alpha1.CustomizedVariable
+		host    string
+		wantErr bool
+	}{
+		{
+			name: "NotConfigured",
+		},
+		{
+			name: "Route",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingGatewayRoute", Value: "observability/tempo-hub-gateway"},
+			},
+			host: "tempo-hub-gateway.apps.example.com",
+		},
+		{
+			name: "InvalidRoute",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingGatewayRoute", Value: "tempo-hub-gateway"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Ref",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingGatewayRef", Value: "service/observability/otlp-gateway"},
+			},
+			host: "10.0.0.1:4317",
+		},
+		{
+			name: "RefTakesPrecedence",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingGatewayRoute", Value: "observability/tempo-hub-gateway"},
+				{Name: "tracingGatewayRef", Value: "service/observability/otlp-gateway"},
+			},
+			host: "10.0.0.1:4317",
+		},
+		{
+			name: "InvalidRef",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingGatewayRef", Value: "observability/otlp-gateway"},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			k8s := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(route, lbService).Build()
+			adoc := &addonapiv1alpha1.AddOnDeploymentConfig{
+				Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+					CustomizedVariables: tc.vars,
+				},
+			}
+
+			host, err := discoverGateway(k8s, adoc)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.host, host)
+		})
+	}
+}
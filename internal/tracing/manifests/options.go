@@ -12,4 +12,11 @@ type Options struct {
 	ConfigMaps             []corev1.ConfigMap
 	OpenTelemetryCollector *otelv1alpha1.OpenTelemetryCollector
 	AddOnDeploymentConfig  *addonapiv1alpha1.AddOnDeploymentConfig
+	// GatewayHost is the host, with its port unless it is the HTTPS one, of a
+	// trace gateway on the hub used by the OTLP exporters that don't have an
+	// endpoint
+	GatewayHost string
+	// HubRunsOperator is set when the managed cluster is the hub and the
+	// OpenTelemetry operator is already installed there
+	HubRunsOperator bool
 }
@@ -1,15 +1,148 @@
 package otelcol
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
+
 	"github.com/ViaQ/logerr/v2/kverrors"
 	"gopkg.in/yaml.v3"
 )
 
-func ConfigFromString(configStr string) (map[string]interface{}, error) {
-	config := make(map[string]interface{})
-	if err := yaml.Unmarshal([]byte(configStr), &config); err != nil {
-		return nil, kverrors.New("couldn't parse the opentelemetry-collector configuration")
+// Config is the configuration of an OpenTelemetry collector. Components are
+// keyed by their ID, type[/name]. Only the settings of the exporters touched by
+// the addon are typed, the other settings are kept as they are.
+type Config struct {
+	Receivers  map[string]Component `yaml:"receivers,omitempty"`
+	Processors map[string]Component `yaml:"processors,omitempty"`
+	Exporters  map[string]Exporter  `yaml:"exporters,omitempty"`
+	Connectors map[string]Component `yaml:"connectors,omitempty"`
+	Extensions map[string]Component `yaml:"extensions,omitempty"`
+	Service    Service              `yaml:"service"`
+}
+
+// Component holds the settings of a receiver, processor, connector or
+// extension, which are specific to its type.
+type Component map[string]interface{}
+
+// Exporter holds the settings of an exporter.
+type Exporter struct {
+	Endpoint string            `yaml:"endpoint,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	TLS      *TLSClientSetting `yaml:"tls,omitempty"`
+	// Settings are the other settings of the exporter, specific to its type
+	Settings map[string]interface{} `yaml:",inline"`
+}
+
+type TLSClientSetting struct {
+	Insecure bool   `yaml:"insecure,omitempty"`
+	CAFile   string `yaml:"ca_file,omitempty"`
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	// Settings are the other TLS settings, e.g. min_version
+	Settings map[string]interface{} `yaml:",inline"`
+}
+
+type Service struct {
+	Extensions []string               `yaml:"extensions,omitempty"`
+	Pipelines  map[string]Pipeline    `yaml:"pipelines"`
+	Telemetry  map[string]interface{} `yaml:"telemetry,omitempty"`
+}
+
+type Pipeline struct {
+	Receivers  []string `yaml:"receivers"`
+	Processors []string `yaml:"processors,omitempty"`
+	Exporters  []string `yaml:"exporters"`
+}
+
+var pipelineTypes = map[string]bool{
+	"traces":  true,
+	"metrics": true,
+	"logs":    true,
+}
+
+// ConfigFromString parses and validates the configuration of an
+// OpenTelemetry collector.
+func ConfigFromString(configStr string) (*Config, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(configStr))
+	decoder.KnownFields(true)
+
+	config := &Config{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, kverrors.Wrap(err, "couldn't parse the opentelemetry-collector configuration")
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
+
+// ConfigToString returns the YAML of the configuration of an OpenTelemetry
+// collector.
+func ConfigToString(config *Config) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return "", kverrors.Wrap(err, "couldn't marshal the opentelemetry-collector configuration")
+	}
+	if err := encoder.Close(); err != nil {
+		return "", kverrors.Wrap(err, "couldn't marshal the opentelemetry-collector configuration")
+	}
+	return buf.String(), nil
+}
+
+// Validate checks that the pipelines and the extensions of the service
+// reference components defined in the configuration. Connectors can be used
+// both as receivers and exporters of pipelines.
+func (c *Config) Validate() error {
+	if len(c.Service.Pipelines) == 0 {
+		return kverrors.New("the opentelemetry-collector configuration has no pipelines")
+	}
+
+	for id, pipeline := range c.Service.Pipelines {
+		pipelineType, _, _ := strings.Cut(id, "/")
+		if !pipelineTypes[pipelineType] {
+			return kverrors.New("unsupported pipeline type, expected traces, metrics or logs", "pipeline", id)
+		}
+		if len(pipeline.Receivers) == 0 {
+			return kverrors.New("pipeline without receivers", "pipeline", id)
+		}
+		if len(pipeline.Exporters) == 0 {
+			return kverrors.New("pipeline without exporters", "pipeline", id)
+		}
+
+		for _, ref := range pipeline.Receivers {
+			if !hasComponent(c.Receivers, ref) && !hasComponent(c.Connectors, ref) {
+				return undefinedComponentError("receiver", ref, id)
+			}
+		}
+		for _, ref := range pipeline.Processors {
+			if !hasComponent(c.Processors, ref) {
+				return undefinedComponentError("processor", ref, id)
+			}
+		}
+		for _, ref := range pipeline.Exporters {
+			if _, ok := c.Exporters[ref]; !ok && !hasComponent(c.Connectors, ref) {
+				return undefinedComponentError("exporter", ref, id)
+			}
+		}
+	}
+
+	for _, ref := range c.Service.Extensions {
+		if !hasComponent(c.Extensions, ref) {
+			return kverrors.New("service references an undefined extension", "extension", ref)
+		}
+	}
+	return nil
+}
+
+func hasComponent(components map[string]Component, id string) bool {
+	_, ok := components[id]
+	return ok
+}
+
+func undefinedComponentError(kind, ref, pipeline string) error {
+	return kverrors.New(fmt.Sprintf("pipeline references an undefined %s", kind), kind, ref, "pipeline", pipeline)
+}
@@ -0,0 +1,130 @@
+package otelcol
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ConfigFromString_RoundTrip(t *testing.T) {
+	otelColConfig := `receivers:
+  otlp:
+    protocols:
+      grpc: null
+processors:
+  batch:
+    timeout: 5s
+exporters:
+  otlphttp:
+    endpoint: https://tempo.example.com
+    headers:
+      x-scope-orgid: cluster-1
+    tls:
+      ca_file: /certs/ca.crt
+      min_version: "1.3"
+    compression: zstd
+extensions:
+  health_check: {}
+service:
+  extensions:
+    - health_check
+  pipelines:
+    traces:
+      receivers:
+        - otlp
+      processors:
+        - batch
+      exporters:
+        - otlphttp
+  telemetry:
+    logs:
+      level: debug
+`
+	cfg, err := ConfigFromString(otelColConfig)
+	require.NoError(t, err)
+
+	otlphttp := cfg.Exporters["otlphttp"]
+	require.Equal(t, "https://tempo.example.com", otlphttp.Endpoint)
+	require.Equal(t, "/certs/ca.crt", otlphttp.TLS.CAFile)
+	require.Equal(t, map[string]interface{}{"min_version": "1.3"}, otlphttp.TLS.Settings)
+	require.Equal(t, map[string]interface{}{"compression": "zstd"}, otlphttp.Settings)
+
+	out, err := ConfigToString(cfg)
+	require.NoError(t, err)
+	require.Equal(t, otelColConfig, out)
+}
+
+func Test_ConfigFromString_Invalid(t *testing.T) {
+	b, err := os.ReadFile("./test_data/simplest.yaml")
+	require.NoError(t, err)
+	_, err = ConfigFromString(string(b))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		config string
+	}{
+		{
+			name:   "MalformedExporters",
+			config: "exporters: [otlp]\nservice:\n  pipelines: {}\n",
+		},
+		{
+			name:   "UnknownField",
+			config: "exporters:\n  otlp:\nservice:\n  pipeline: {}\n",
+		},
+		{
+			name:   "NoPipelines",
+			config: "receivers:\n  otlp:\nexporters:\n  otlp:\n",
+		},
+		{
+			name:   "UnsupportedPipelineType",
+			config: "receivers:\n  otlp:\nexporters:\n  otlp:\nservice:\n  pipelines:\n    spans:\n      receivers: [otlp]\n      exporters: [otlp]\n",
+		},
+		{
+			name:   "PipelineWithoutExporters",
+			config: "receivers:\n  otlp:\nservice:\n  pipelines:\n    traces:\n      receivers: [otlp]\n",
+		},
+		{
+			name:   "UndefinedReceiver",
+			config: "receivers:\n  otlp:\nexporters:\n  otlp:\nservice:\n  pipelines:\n    traces:\n      receivers: [jaeger]\n      exporters: [otlp]\n",
+		},
+		{
+			name:   "UndefinedProcessor",
+			config: "receivers:\n  otlp:\nexporters:\n  otlp:\nservice:\n  pipelines:\n    traces:\n      receivers: [otlp]\n      processors: [batch]\n      exporters: [otlp]\n",
+		},
+		{
+			name:   "UndefinedExporter",
+			config: "receivers:\n  otlp:\nexporters:\n  otlp:\nservice:\n  pipelines:\n    traces:\n      receivers: [otlp]\n      exporters: [otlphttp]\n",
+		},
+		{
+			name:   "UndefinedExtension",
+			config: "receivers:\n  otlp:\nexporters:\n  otlp:\nservice:\n  extensions: [health_check]\n  pipelines:\n    traces:\n      receivers: [otlp]\n      exporters: [otlp]\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ConfigFromString(tc.config)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_ConfigFromString_Connectors(t *testing.T) {
+	otelColConfig := `receivers:
+  otlp:
+exporters:
+  otlp:
+connectors:
+  spanmetrics:
+service:
+  pipelines:
+    traces:
+      receivers: [otlp]
+      exporters: [spanmetrics]
+    metrics:
+      receivers: [spanmetrics]
+      exporters: [otlp]
+`
+	_, err := ConfigFromString(otelColConfig)
+	require.NoError(t, err)
+}
@@ -2,12 +2,14 @@ package otelcol
 
 import (
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/ViaQ/logerr/v2/kverrors"
 	corev1 "k8s.io/api/core/v1"
 )
 
-func ConfigureExportersSecrets(cfg map[string]interface{}, secret corev1.Secret, annotation string) error {
+func ConfigureExportersSecrets(cfg *Config, secret corev1.Secret, annotation string) error {
 	otelExporterName, ok := secret.Annotations[annotation]
 	if !ok {
 		return nil
@@ -18,25 +20,16 @@ func ConfigureExportersSecrets(cfg map[string]interface{}, secret corev1.Secret,
 		return err
 	}
 
-	for exporterName, config := range exporters {
-		if otelExporterName != exporterName {
-			continue
-		}
-		var configMap map[string]interface{}
-		if config == nil {
-			configMap = make(map[string]interface{})
-			exporters[otelExporterName] = configMap
-		} else {
-			configMap = config.(map[string]interface{})
-		}
-
-		configureExporterSecrets(configMap, secret)
-
+	exporter, ok := exporters[otelExporterName]
+	if !ok {
+		return nil
 	}
+	configureExporterSecrets(&exporter, secret)
+	exporters[otelExporterName] = exporter
 	return nil
 }
 
-func ConfigureExporters(cfg map[string]interface{}, cm corev1.ConfigMap, clusterName string, annotation string) error {
+func ConfigureExporters(cfg *Config, cm corev1.ConfigMap, clusterName string, annotation string) error {
 	otelExporterName, ok := cm.Annotations[annotation]
 	if !ok {
 		return nil
@@ -47,60 +40,90 @@ func ConfigureExporters(cfg map[string]interface{}, cm corev1.ConfigMap, cluster
 		return err
 	}
 
-	for exporterName, config := range exporters {
-		if otelExporterName != exporterName {
+	exporter, ok := exporters[otelExporterName]
+	if !ok {
+		return nil
+	}
+	if err := configureExporterEndpoint(&exporter, cm); err != nil {
+		return err
+	}
+	configureTenant(&exporter, clusterName)
+	exporters[otelExporterName] = exporter
+	return nil
+}
+
+// ConfigureExportersGateway sets the endpoint of the OTLP exporters that don't
+// have one to the gateway reachable on gatewayHost, and identifies the cluster
+// as tenant of the gateway. The gateway is reached on the HTTPS port unless
+// gatewayHost has one.
+func ConfigureExportersGateway(cfg *Config, gatewayHost string, clusterName string) error {
+	exporters, err := getExporters(cfg)
+	if err != nil {
+		return err
+	}
+
+	grpcHost := gatewayHost
+	if _, _, err := net.SplitHostPort(gatewayHost); err != nil {
+		grpcHost = net.JoinHostPort(strings.Trim(gatewayHost, "[]"), "443")
+	}
+
+	for exporterName, exporter := range exporters {
+		var endpoint string
+		exporterType, _, _ := strings.Cut(exporterName, "/")
+		switch exporterType {
+		case "otlp":
+			endpoint = grpcHost
+		case "otlphttp":
+			endpoint = fmt.Sprintf("https://%s", gatewayHost)
+		default:
 			continue
 		}
-		var exporterConfig map[string]interface{}
-		if config == nil {
-			exporterConfig = make(map[string]interface{})
-			exporters[otelExporterName] = exporterConfig
-		} else {
-			exporterConfig = config.(map[string]interface{})
-		}
 
-		err := configureExporterEndpoint(exporterConfig, cm)
-		if err != nil {
-			return err
+		// Endpoints set in the template or by a ConfigMap take precedence
+		if exporter.Endpoint != "" {
+			continue
 		}
-
-		configureTenant(exporterConfig, clusterName)
+		exporter.Endpoint = endpoint
+		configureTenant(&exporter, clusterName)
+		exporters[exporterName] = exporter
 	}
 	return nil
 }
 
-func getExporters(cfg map[string]interface{}) (map[string]interface{}, error) {
-	exportersField, ok := cfg["exporters"]
-	if !ok {
+func getExporters(cfg *Config) (map[string]Exporter, error) {
+	if len(cfg.Exporters) == 0 {
 		return nil, kverrors.New("no exporters available as part of the configuration")
 	}
-
-	exporters := exportersField.(map[string]interface{})
-	return exporters, nil
+	return cfg.Exporters, nil
 }
 
-func configureExporterSecrets(exporter map[string]interface{}, secret corev1.Secret) {
-	certConfig := make(map[string]interface{})
+func configureExporterSecrets(exporter *Exporter, secret corev1.Secret) {
 	folder := fmt.Sprintf("/%s", secret.Name)
-	certConfig["insecure"] = false
-	certConfig["cert_file"] = fmt.Sprintf("%s/tls.crt", folder)
-	certConfig["key_file"] = fmt.Sprintf("%s/tls.key", folder)
-	certConfig["ca_file"] = fmt.Sprintf("%s/ca-bundle.crt", folder)
-
-	exporter["tls"] = certConfig
+	tls := exporter.TLS
+	if tls == nil {
+		tls = &TLSClientSetting{}
+	}
+	tls.Insecure = false
+	tls.CertFile = fmt.Sprintf("%s/tls.crt", folder)
+	tls.KeyFile = fmt.Sprintf("%s/tls.key", folder)
+	tls.CAFile = fmt.Sprintf("%s/ca-bundle.crt", folder)
+	exporter.TLS = tls
 }
 
-func configureExporterEndpoint(exporter map[string]interface{}, cm corev1.ConfigMap) error {
+func configureExporterEndpoint(exporter *Exporter, cm corev1.ConfigMap) error {
 	url := cm.Data["endpoint"]
 	if url == "" {
 		return kverrors.New("no value for 'endpoint' in configmap", "name", cm.Name)
 	}
-	exporter["endpoint"] = url
+	exporter.Endpoint = url
 	return nil
 }
 
-func configureTenant(exporter map[string]interface{}, clusterName string) {
-	headers := make(map[string]string)
-	headers["x-scope-orgid"] = clusterName
-	exporter["headers"] = headers
+// configureTenant identifies the cluster as tenant of the exporter
+// destination, keeping the other headers of the exporter.
+func configureTenant(exporter *Exporter, clusterName string) {
+	if exporter.Headers == nil {
+		exporter.Headers = map[string]string{}
+	}
+	exporter.Headers["x-scope-orgid"] = clusterName
 }
@@ -36,9 +36,7 @@ func Test_ConfigureExportersSecrets(t *testing.T) {
 	err = ConfigureExportersSecrets(cfg, secret, annotation)
 	require.NoError(t, err)
 
-	exportersField := cfg["exporters"]
-	exporters := exportersField.(map[string]interface{})
-	require.Nil(t, exporters["debug"])
+	require.Nil(t, cfg.Exporters["debug"].TLS)
 
 	b, err = os.ReadFile("./test_data/basic_otelhttp.yaml")
 	require.NoError(t, err)
@@ -49,11 +47,11 @@ func Test_ConfigureExportersSecrets(t *testing.T) {
 	err = ConfigureExportersSecrets(cfg, secret, annotation)
 	require.NoError(t, err)
 
-	exportersField = cfg["exporters"]
-	exporters = exportersField.(map[string]interface{})
-	otlphttpField := exporters["otlphttp"]
-	otlphttp := otlphttpField.(map[string]interface{})
-	require.NotNil(t, otlphttp["tls"])
+	require.Equal(t, &TLSClientSetting{
+		CAFile:   "/tracing-otlphttp-auth/ca-bundle.crt",
+		CertFile: "/tracing-otlphttp-auth/tls.crt",
+		KeyFile:  "/tracing-otlphttp-auth/tls.key",
+	}, cfg.Exporters["otlphttp"].TLS)
 }
 
 func Test_ConfigureExportersEndpoints(t *testing.T) {
@@ -82,9 +80,7 @@ func Test_ConfigureExportersEndpoints(t *testing.T) {
 	err = ConfigureExporters(cfg, cm, "cluster", annotation)
 	require.NoError(t, err)
 
-	exportersField := cfg["exporters"]
-	exporters := exportersField.(map[string]interface{})
-	require.Nil(t, exporters["debug"])
+	require.Empty(t, cfg.Exporters["debug"].Endpoint)
 
 	b, err = os.ReadFile("./test_data/basic_otelhttp.yaml")
 	require.NoError(t, err)
@@ -95,11 +91,9 @@ func Test_ConfigureExportersEndpoints(t *testing.T) {
 	err = ConfigureExporters(cfg, cm, "cluster", annotation)
 	require.NoError(t, err)
 
-	exportersField = cfg["exporters"]
-	exporters = exportersField.(map[string]interface{})
-	otlphttpField := exporters["otlphttp"]
-	otlphttp := otlphttpField.(map[string]interface{})
-	require.NotNil(t, otlphttp["endpoint"])
+	otlphttp := cfg.Exporters["otlphttp"]
+	require.Equal(t, "http://example.namespace.svc", otlphttp.Endpoint)
+	require.Equal(t, map[string]string{"x-scope-orgid": "cluster"}, otlphttp.Headers)
 }
 
 func Test_getExporters(t *testing.T) {
@@ -113,13 +107,13 @@ func Test_getExporters(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, exporters, 1)
 
-	cfg = make(map[string]interface{})
+	cfg = &Config{}
 	_, err = getExporters(cfg)
 	require.Error(t, err)
 }
 
 func Test_configureExporterSecrets(t *testing.T) {
-	exporter := make(map[string]interface{})
+	exporter := Exporter{}
 	secret := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "tracing-otlphttp-auth",
@@ -131,12 +125,12 @@ func Test_configureExporterSecrets(t *testing.T) {
 			"tls.key": []byte("data"),
 		},
 	}
-	configureExporterSecrets(exporter, secret)
-	require.NotNil(t, exporter["tls"])
+	configureExporterSecrets(&exporter, secret)
+	require.NotNil(t, exporter.TLS)
 }
 
 func Test_configureExporterEndpoint(t *testing.T) {
-	exporter := make(map[string]interface{})
+	exporter := Exporter{}
 	cm := corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "tracing-auth",
@@ -150,10 +144,10 @@ func Test_configureExporterEndpoint(t *testing.T) {
 		},
 	}
 
-	err := configureExporterEndpoint(exporter, cm)
+	err := configureExporterEndpoint(&exporter, cm)
 	require.NoError(t, err)
 
-	require.NotNil(t, exporter["endpoint"])
+	require.Equal(t, "http://example.namespace.svc", exporter.Endpoint)
 
 	cm = corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -168,6 +162,64 @@ func Test_configureExporterEndpoint(t *testing.T) {
 		},
 	}
 
-	err = configureExporterEndpoint(exporter, cm)
+	err = configureExporterEndpoint(&exporter, cm)
 	require.Error(t, err)
 }
+
+func Test_ConfigureExportersGateway(t *testing.T) {
+	b, err := os.ReadFile("./test_data/gateway.yaml")
+	require.NoError(t, err)
+	cfg, err := ConfigFromString(string(b))
+	require.NoError(t, err)
+
+	err = ConfigureExportersGateway(cfg, "tempo-hub-gateway.apps.example.com", "cluster-1")
+	require.NoError(t, err)
+
+	require.Empty(t, cfg.Exporters["debug"].Endpoint)
+
+	otlp := cfg.Exporters["otlp"]
+	require.Equal(t, "tempo-hub-gateway.apps.example.com:443", otlp.Endpoint)
+	require.Equal(t, map[string]string{"x-scope-orgid": "cluster-1"}, otlp.Headers)
+
+	otlphttp := cfg.Exporters["otlphttp/hub"]
+	require.Equal(t, "https://tempo-hub-gateway.apps.example.com", otlphttp.Endpoint)
+	require.Equal(t, map[string]string{"x-scope-orgid": "cluster-1"}, otlphttp.Headers)
+
+	external := cfg.Exporters["otlphttp/external"]
+	require.Equal(t, "https://tempo.example.com", external.Endpoint)
+	require.Nil(t, external.Headers)
+}
+
+func Test_ConfigureExportersGateway_Port(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		gatewayHost  string
+		otlpEndpoint string
+		httpEndpoint string
+	}{
+		{
+			name:         "InCluster",
+			gatewayHost:  "tempo-hub-gateway.observability.svc:8080",
+			otlpEndpoint: "tempo-hub-gateway.observability.svc:8080",
+			httpEndpoint: "https://tempo-hub-gateway.observability.svc:8080",
+		},
+		{
+			name:         "LoadBalancerIPv6",
+			gatewayHost:  "[2001:db8::1]",
+			otlpEndpoint: "[2001:db8::1]:443",
+			httpEndpoint: "https://[2001:db8::1]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := os.ReadFile("./test_data/gateway.yaml")
+			require.NoError(t, err)
+			cfg, err := ConfigFromString(string(b))
+			require.NoError(t, err)
+
+			err = ConfigureExportersGateway(cfg, tc.gatewayHost, "cluster-1")
+			require.NoError(t, err)
+			require.Equal(t, tc.otlpEndpoint, cfg.Exporters["otlp"].Endpoint)
+			require.Equal(t, tc.httpEndpoint, cfg.Exporters["otlphttp/hub"].Endpoint)
+		})
+	}
+}
@@ -2,15 +2,57 @@ package manifests
 
 import (
 	"encoding/json"
-	"fmt"
+	"strings"
 
 	"github.com/ViaQ/logerr/v2/kverrors"
+
 	otelv1alpha1 "github.com/open-telemetry/opentelemetry-operator/apis/v1alpha1"
 	"github.com/rhobs/multicluster-observability-addon/internal/tracing/manifests/otelcol"
-	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 )
 
+// customizedVariable returns the value of the AddOnDeploymentConfig
+// customized variable with the given name.
+func customizedVariable(resources Options, name string) (string, bool) {
+	adoc := resources.AddOnDeploymentConfig
+	if adoc == nil {
+		return "", false
+	}
+
+	for _, keyvalue := range adoc.Spec.CustomizedVariables {
+		if keyvalue.Name == name {
+			return keyvalue.Value, true
+		}
+	}
+	return "", false
+}
+
+// BuildNamespace returns the namespace where the OpenTelemetry collector is
+// deployed on the spoke cluster.
+func BuildNamespace(resources Options) (string, error) {
+	return buildNamespace(resources, namespaceValueKey, defaultNamespace)
+}
+
+func buildOperatorNamespace(resources Options) (string, error) {
+	return buildNamespace(resources, operatorNamespaceValueKey, defaultOperatorNamespace)
+}
+
+// buildNamespace returns the namespace set by the customized variable with the
+// given name, or defaultValue when it isn't set.
+func buildNamespace(resources Options, name, defaultValue string) (string, error) {
+	namespace, ok := customizedVariable(resources, name)
+	if !ok || namespace == "" {
+		return defaultValue, nil
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", kverrors.New("invalid customized variable", "name", name, "value", namespace, "reason", strings.Join(errs, ", "))
+	}
+	return namespace, nil
+}
+
 func buildSecrets(resources Options) ([]SecretValue, error) {
 	secretsValue := []SecretValue{}
 	for _, secret := range resources.Secrets {
@@ -40,27 +82,33 @@ func buildOtelColSpec(resources Options) (*otelv1alpha1.OpenTelemetryCollectorSp
 		}
 	}
 
+	templateWithAddOnDeploymentConfig(&resources.OpenTelemetryCollector.Spec, resources.AddOnDeploymentConfig)
+
+	if resources.GatewayHost != "" {
+		if err := templateWithGateway(&resources); err != nil {
+			return nil, err
+		}
+	}
+
 	return &resources.OpenTelemetryCollector.Spec, nil
 }
 
 func templateWithSecret(spec *otelv1alpha1.OpenTelemetryCollectorSpec, secret corev1.Secret) error {
 	cfg, err := otelcol.ConfigFromString(spec.Config)
 	if err != nil {
-		return nil
+		return err
 	}
 
-	// iblancasa: add verifications for the exporters
-
 	err = otelcol.ConfigureExportersSecrets(cfg, secret, AnnotationTargetOutputName)
 	if err != nil {
 		return err
 	}
 
-	yamlConfig, err := yaml.Marshal(&cfg)
+	yamlConfig, err := otelcol.ConfigToString(cfg)
 	if err != nil {
-		return kverrors.New(fmt.Sprint("error while marshaling OTEL Configuration: %w", err))
+		return err
 	}
-	spec.Config = string(yamlConfig)
+	spec.Config = yamlConfig
 
 	otelcol.ConfigureVolumes(spec, secret)
 	otelcol.ConfigureVolumeMounts(spec, secret)
@@ -78,10 +126,76 @@ func templateWithConfigMap(resource *Options, configmap corev1.ConfigMap) error
 		return err
 	}
 
-	yamlConfig, err := yaml.Marshal(&cfg)
+	yamlConfig, err := otelcol.ConfigToString(cfg)
 	if err != nil {
-		return kverrors.New(fmt.Sprint("error while marshaling OTEL Configuration: %w", err))
+		return err
 	}
-	resource.OpenTelemetryCollector.Spec.Config = string(yamlConfig)
+	resource.OpenTelemetryCollector.Spec.Config = yamlConfig
 	return nil
 }
+
+func templateWithGateway(resource *Options) error {
+	cfg, err := otelcol.ConfigFromString(resource.OpenTelemetryCollector.Spec.Config)
+	if err != nil {
+		return err
+	}
+	err = otelcol.ConfigureExportersGateway(cfg, resource.GatewayHost, resource.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	yamlConfig, err := otelcol.ConfigToString(cfg)
+	if err != nil {
+		return err
+	}
+	resource.OpenTelemetryCollector.Spec.Config = yamlConfig
+	return nil
+}
+
+// templateWithAddOnDeploymentConfig schedules the collector according to the
+// NodePlacement of the AddOnDeploymentConfig, unless the template already sets
+// a node selector or tolerations, rewrites the collector image set in the
+// template with the configured registries and sets the proxy environment
+// variables of the collector from the ProxyConfig.
+func templateWithAddOnDeploymentConfig(spec *otelv1alpha1.OpenTelemetryCollectorSpec, adoc *addonapiv1alpha1.AddOnDeploymentConfig) {
+	if adoc == nil {
+		return
+	}
+
+	if placement := adoc.Spec.NodePlacement; placement != nil {
+		if len(spec.NodeSelector) == 0 {
+			spec.NodeSelector = placement.NodeSelector
+		}
+		if len(spec.Tolerations) == 0 {
+			spec.Tolerations = placement.Tolerations
+		}
+	}
+
+	if spec.Image != "" {
+		spec.Image = addonfactory.OverrideImage(adoc.Spec.Registries, spec.Image)
+	}
+
+	proxy := adoc.Spec.ProxyConfig
+	if proxy.HTTPProxy == "" && proxy.HTTPSProxy == "" {
+		return
+	}
+	for _, env := range []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: proxy.HTTPProxy},
+		{Name: "HTTPS_PROXY", Value: proxy.HTTPSProxy},
+		{Name: "NO_PROXY", Value: proxy.NoProxy},
+	} {
+		if env.Value == "" || hasEnv(spec.Env, env.Name) {
+			continue
+		}
+		spec.Env = append(spec.Env, env)
+	}
+}
+
+func hasEnv(envs []corev1.EnvVar, name string) bool {
+	for _, env := range envs {
+		if env.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package manifests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+)
+
+func Test_BuildNamespaces(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		vars              []addonapiv1alpha1.CustomizedVariable
+		namespace         string
+		operatorNamespace string
+		wantErr           bool
+	}{
+		{
+			name:              "Defaults",
+			namespace:         "spoke-otelcol",
+			operatorNamespace: "openshift-opentelemetry-operator",
+		},
+		{
+			name: "Custom",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingNamespace", Value: "otel"},
+				{Name: "tracingOperatorNamespace", Value: "otel-operator"},
+			},
+			namespace:         "otel",
+			operatorNamespace: "otel-operator",
+		},
+		{
+			name: "InvalidNamespace",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingNamespace", Value: "otel/collector"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "InvalidOperatorNamespace",
+			vars: []addonapiv1alpha1.CustomizedVariable{
+				{Name: "tracingOperatorNamespace", Value: "OTel"},
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resources := Options{
+				AddOnDeploymentConfig: &addonapiv1alpha1.AddOnDeploymentConfig{
+					Spec: addonapiv1alpha1.AddOnDeploymentConfigSpec{
+						CustomizedVariables: tc.vars,
+					},
+				},
+			}
+
+			namespace, err := BuildNamespace(resources)
+			operatorNamespace, operatorErr := buildOperatorNamespace(resources)
+			if tc.wantErr {
+				require.True(t, err != nil || operatorErr != nil)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, operatorErr)
+			require.Equal(t, tc.namespace, namespace)
+			require.Equal(t, tc.operatorNamespace, operatorNamespace)
+		})
+	}
+}
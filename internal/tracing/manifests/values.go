@@ -7,9 +7,12 @@ import (
 )
 
 type TracingValues struct {
-	Enabled     bool          `json:"enabled"`
-	OTELColSpec string        `json:"otelColSpec"`
-	Secrets     []SecretValue `json:"secrets"`
+	Enabled           bool          `json:"enabled"`
+	Namespace         string        `json:"namespace"`
+	OperatorNamespace string        `json:"operatorNamespace"`
+	InstallOperator   bool          `json:"installOperator"`
+	OTELColSpec       string        `json:"otelColSpec"`
+	Secrets           []SecretValue `json:"secrets"`
 }
 
 type SecretValue struct {
@@ -19,9 +22,22 @@ type SecretValue struct {
 
 func BuildValues(opts Options) (TracingValues, error) {
 	values := TracingValues{
-		Enabled: true,
+		Enabled:         true,
+		InstallOperator: !opts.HubRunsOperator,
 	}
 
+	namespace, err := BuildNamespace(opts)
+	if err != nil {
+		return values, err
+	}
+	values.Namespace = namespace
+
+	operatorNamespace, err := buildOperatorNamespace(opts)
+	if err != nil {
+		return values, err
+	}
+	values.OperatorNamespace = operatorNamespace
+
 	secrets, err := buildSecrets(opts)
 	if err != nil {
 		return values, err
@@ -1,6 +1,8 @@
 package manifests
 
 import (
+	"fmt"
+
 	v1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/rhobs/multicluster-observability-addon/internal/addon/authentication"
 	"github.com/rhobs/multicluster-observability-addon/internal/manifests"
@@ -8,18 +10,35 @@ import (
 
 const (
 	AnnotationTargetOutputName = "tracing.mcoa.openshift.io/target-output-name"
+
+	// operatorNamespaceValueKey sets the namespace where the OpenTelemetry
+	// operator is installed on the spoke cluster
+	operatorNamespaceValueKey = "tracingOperatorNamespace"
+	defaultOperatorNamespace  = "openshift-opentelemetry-operator"
+	// namespaceValueKey sets the namespace where the OpenTelemetry collector
+	// is deployed on the spoke cluster
+	namespaceValueKey = "tracingNamespace"
+	defaultNamespace  = "spoke-otelcol"
+
+	// certDNSNameCollector is formatted with the namespace of the collector
+	certDNSNameCollector = "otelcol.%s.svc"
 )
 
-var AuthDefaultConfig = &authentication.Config{
-	MTLSConfig: manifests.MTLSConfig{
-		CommonName: "",
-		Subject: &v1.X509Subject{
-			OrganizationalUnits: []string{
-				"multicluster-observability-addon",
+// BuildAuthConfig returns the configuration used to generate the secrets of
+// the exporters. The certificates are issued for commonName and for the
+// collector service in the given namespace.
+func BuildAuthConfig(commonName, namespace string) *authentication.Config {
+	return &authentication.Config{
+		MTLSConfig: manifests.MTLSConfig{
+			CommonName: commonName,
+			Subject: &v1.X509Subject{
+				OrganizationalUnits: []string{
+					"multicluster-observability-addon",
+				},
+			},
+			DNSNames: []string{
+				fmt.Sprintf(certDNSNameCollector, namespace),
 			},
 		},
-		DNSNames: []string{
-			"otelcol.spoke-otelcol.svc",
-		},
-	},
+	}
 }
@@ -16,6 +16,8 @@ import (
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/rhobs/multicluster-observability-addon/internal/addon"
 	addonhelm "github.com/rhobs/multicluster-observability-addon/internal/addon/helm"
+	obsv1 "github.com/rhobs/multicluster-observability-addon/internal/logging/apis/observability/v1"
+	monitoringv1alpha1 "github.com/rhobs/multicluster-observability-addon/internal/metrics/apis/monitoring/v1alpha1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -103,6 +105,16 @@ func runController(ctx context.Context, kubeConfig *rest.Config) error {
 	if err != nil {
 		return err
 	}
+	// Necessary to render ClusterLogForwarders for Logging 6.x
+	err = obsv1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		return err
+	}
+	// Necessary to render PrometheusAgents
+	err = monitoringv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		return err
+	}
 	// Necessary to reconcile OpenTelemetryCollectors
 	err = otelv1alpha1.AddToScheme(scheme.Scheme)
 	if err != nil {